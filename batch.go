@@ -0,0 +1,260 @@
+package orbit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Defaults for Router.BatchWindow and Router.MaxBatchSize.
+const (
+	DefaultBatchWindow  = 2 * time.Millisecond
+	DefaultMaxBatchSize = 100
+)
+
+// The FromRequestBatchable interface is an optional extra for param types that
+// can be looked up more efficiently many at a time, like users that can be
+// fetched from a database with one query instead of one each.
+//
+// If a param type implements it, the router collects the raw values of that
+// type that concurrent requests need within a short window (see
+// Router.BatchWindow) and resolves them all with one call to
+// FromRequestBatch, instead of calling FromRequest for each.
+//
+// FromRequestBatch should return a map from each raw value to what it resolves
+// to, or to an error if that one failed. Raw values missing from the map are
+// treated as not found. For example:
+//
+//	func (u User) FromRequestBatch(uids []string) map[string]any {
+//		users, err := db.GetUsers(uids)
+//		results := make(map[string]any, len(uids))
+//		for _, uid := range uids {
+//			if err != nil {
+//				results[uid] = err
+//			} else if user, ok := users[uid]; ok {
+//				results[uid] = user
+//			}
+//		}
+//		return results
+//	}
+//
+// Routes that use the same value of a type share batches. Values are the
+// same if they're == (or, for types that can't be compared with ==, like
+// structs holding funcs, if they're reflect.DeepEqual, which is never true
+// for non-nil funcs). Other routes get batches of their own, so use a pointer
+// type if you want routes to share a value that can't be compared. One route
+// can't use different values of the same type. Types still need a FromRequest method to be used in
+// RouteParams, but it won't be called by the router.
+type FromRequestBatchable interface {
+	FromRequestBatch([]string) map[string]any
+}
+
+// A batcher collects lookups for one FromRequestBatchable type, and resolves
+// them in batches.
+type batcher struct {
+	el      FromRequestBatchable
+	window  time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	pending *batch // The batch currently collecting lookups, if there is one.
+}
+
+// A batch is a set of raw values to be looked up together.
+type batch struct {
+	keys    []string
+	seen    map[string]bool
+	once    sync.Once     // Makes sure the batch is only run once.
+	done    chan struct{} // Closed once results are set.
+	results map[string]any
+}
+
+// batchersCtxKey is the context key a route's batchers are stored under.
+type batchersCtxKey struct{}
+
+// withBatchers returns a copy of ctx carrying batchers.
+func withBatchers(ctx context.Context, batchers map[reflect.Type]*batcher) context.Context {
+	return context.WithValue(ctx, batchersCtxKey{}, batchers)
+}
+
+// fromRequestBatch resolves raw as el. If ctx has a batcher for el's type
+// (from the route being handled), raw is batched up with other lookups. Otherwise (e.g. outside of the router) it's
+// looked up on its own.
+func fromRequestBatch(ctx context.Context, el FromRequestBatchable, raw string) (any, error) {
+
+	batchers, _ := ctx.Value(batchersCtxKey{}).(map[reflect.Type]*batcher)
+	if b, ok := batchers[reflect.TypeOf(el)]; ok {
+		return b.load(ctx, raw)
+	}
+
+	return batchResult(el.FromRequestBatch([]string{raw}), raw)
+
+}
+
+// batchResult gets the result for raw out of what FromRequestBatch returned.
+func batchResult(results map[string]any, raw string) (any, error) {
+
+	result, ok := results[raw]
+	if !ok {
+		return nil, NotFound(fmt.Sprintf("%s not found", raw))
+	}
+
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+
+	return result, nil
+
+}
+
+// newBatchers gives each route a batcher for each FromRequestBatchable type
+// its params use, including ones wrapped by other param types (like
+// CachedParam). Routes using the same value of a type (see sameParam) share a
+// batcher. It returns an error if a route uses different values of the same
+// type, since its batchers are looked up by type.
+func newBatchers(routes []route, window time.Duration, maxSize int) error {
+
+	if window <= 0 {
+		window = DefaultBatchWindow
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBatchSize
+	}
+
+	var shared []*batcher
+	for i := range routes {
+		routes[i].batchers = nil
+		for _, el := range routes[i].params {
+			for {
+				if batchable, ok := el.(FromRequestBatchable); ok {
+					if err := routes[i].addBatcher(batchable, &shared, window, maxSize); err != nil {
+						return err
+					}
+				}
+				wrapper, ok := el.(paramWrapper)
+				if !ok {
+					break
+				}
+				el = wrapper.unwrapParam()
+			}
+		}
+	}
+
+	return nil
+
+}
+
+// addBatcher gives the route a batcher for el, reusing one from shared if it
+// batches the same value, or adding a new one to shared if not.
+func (r *route) addBatcher(el FromRequestBatchable, shared *[]*batcher, window time.Duration, maxSize int) error {
+
+	if existing, ok := r.batchers[reflect.TypeOf(el)]; ok {
+		if !sameParam(existing.el, el) {
+			return errMisconfigured(fmt.Sprintf("route '%s' uses different values of %T, which would be batched together", r.path, el))
+		}
+		return nil
+	}
+
+	if r.batchers == nil {
+		r.batchers = make(map[reflect.Type]*batcher)
+	}
+
+	for _, b := range *shared {
+		if sameParam(b.el, el) {
+			r.batchers[reflect.TypeOf(el)] = b
+			return nil
+		}
+	}
+
+	b := &batcher{el: el, window: window, maxSize: maxSize}
+	*shared = append(*shared, b)
+	r.batchers[reflect.TypeOf(el)] = b
+	return nil
+
+}
+
+// sameParam reports whether a and b are the same param value: == if they can
+// be compared, and reflect.DeepEqual if not.
+func sameParam(a any, b any) bool {
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+
+	if va.Comparable() && vb.Comparable() {
+		return a == b
+	}
+
+	return reflect.DeepEqual(a, b)
+
+}
+
+// load adds raw to the pending batch (starting one if there isn't one) and
+// waits for the batch's result.
+func (b *batcher) load(ctx context.Context, raw string) (any, error) {
+
+	b.mu.Lock()
+
+	current := b.pending
+	if current == nil {
+		current = &batch{seen: make(map[string]bool), done: make(chan struct{})}
+		b.pending = current
+		time.AfterFunc(b.window, func() { b.run(current) })
+	}
+
+	if !current.seen[raw] {
+		current.seen[raw] = true
+		current.keys = append(current.keys, raw)
+	}
+
+	// If the batch is full, don't wait for the window to end.
+	full := len(current.keys) >= b.maxSize
+	if full {
+		b.pending = nil
+	}
+
+	b.mu.Unlock()
+
+	if full {
+		b.run(current)
+	}
+
+	select {
+	case <-current.done:
+		return batchResult(current.results, raw)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+}
+
+// run looks up everything in a batch, if it hasn't been already.
+func (b *batcher) run(current *batch) {
+	current.once.Do(func() {
+
+		b.mu.Lock()
+		if b.pending == current {
+			b.pending = nil
+		}
+		b.mu.Unlock()
+
+		defer close(current.done)
+
+		// If FromRequestBatch panics, every request waiting on it fails.
+		defer func() {
+			if value := recover(); value != nil {
+				err := newErrPanic(value)
+				current.results = make(map[string]any, len(current.keys))
+				for _, key := range current.keys {
+					current.results[key] = err
+				}
+			}
+		}()
+
+		current.results = b.el.FromRequestBatch(current.keys)
+
+	})
+}
@@ -0,0 +1,231 @@
+package orbit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A record of the batches a testTypeBatched was asked for.
+type testBatchLog struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+// A batchable param type. "bad" fails, "missing" is left out of the results,
+// and "panic" panics.
+type testTypeBatched struct {
+	log   *testBatchLog
+	value string
+}
+
+func (x testTypeBatched) FromRequest(param string) (any, error) {
+	panic("FromRequest shouldn't be called when FromRequestBatch exists")
+}
+
+func (x testTypeBatched) FromRequestBatch(params []string) map[string]any {
+
+	x.log.mu.Lock()
+	x.log.batches = append(x.log.batches, append([]string(nil), params...))
+	x.log.mu.Unlock()
+
+	results := make(map[string]any, len(params))
+	for _, param := range params {
+		switch param {
+		case "bad":
+			results[param] = BadRequest("bad")
+		case "missing":
+		case "panic":
+			panic("oh no")
+		default:
+			results[param] = testTypeBatched{log: x.log, value: param}
+		}
+	}
+	return results
+
+}
+
+// serveConcurrently serves a GET for each path at once, and returns the status
+// for each.
+func serveConcurrently(r Router, paths ...string) []int {
+	statuses := make([]int, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			statuses[i] = w.Code
+		}(i, path)
+	}
+	wg.Wait()
+	return statuses
+}
+
+func Test_Router_E2E_Batched(t *testing.T) {
+
+	// setup
+	log := &testBatchLog{}
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		assert.Equal(t, r.URL.Path[len("/user/"):], params["user"].(testTypeBatched).value)
+	})
+
+	r := NewRouter()
+	r.BatchWindow = 200 * time.Millisecond
+	r.Handle("/user/{user}", handler, nil, RouteParams{"user": testTypeBatched{log: log}}, nil)
+	assert.NoError(t, r.Bake())
+
+	// do
+	statuses := serveConcurrently(r, "/user/a", "/user/b", "/user/a", "/user/bad", "/user/missing")
+
+	// check - one batch, with each value once, and errors for the right requests
+	assert.Equal(t, []int{200, 200, 200, 400, 404}, statuses)
+	if assert.Len(t, log.batches, 1) {
+		sort.Strings(log.batches[0])
+		assert.Equal(t, []string{"a", "b", "bad", "missing"}, log.batches[0])
+	}
+
+}
+
+func Test_Router_E2E_Batched_MaxSize(t *testing.T) {
+
+	// setup
+	log := &testBatchLog{}
+	r := NewRouter()
+	r.BatchWindow = time.Hour // So batches only run once they're full.
+	r.MaxBatchSize = 2
+	r.Handle("/user/{user}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, RouteParams{"user": testTypeBatched{log: log}}, nil)
+
+	// do
+	statuses := serveConcurrently(r, "/user/a", "/user/b", "/user/c", "/user/d")
+
+	// check
+	assert.Equal(t, []int{200, 200, 200, 200}, statuses)
+	assert.Len(t, log.batches, 2)
+
+}
+
+func Test_Router_E2E_Batched_Panic(t *testing.T) {
+
+	// setup
+	log := &testBatchLog{}
+	r := NewRouter()
+	r.BatchWindow = 200 * time.Millisecond
+	r.Handle("/user/{user}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, RouteParams{"user": testTypeBatched{log: log}}, nil)
+
+	// do
+	statuses := serveConcurrently(r, "/user/a", "/user/panic")
+
+	// check - the whole batch fails
+	assert.Equal(t, []int{500, 500}, statuses)
+
+}
+
+func Test_fromRequestBatch_NoRouter(t *testing.T) {
+
+	// setup
+	log := &testBatchLog{}
+
+	// do
+	result, err := fromRequestBatch(context.Background(), testTypeBatched{log: log}, "a")
+	_, missingErr := fromRequestBatch(context.Background(), testTypeBatched{log: log}, "missing")
+
+	// check
+	assert.NoError(t, err)
+	assert.Equal(t, testTypeBatched{log: log, value: "a"}, result)
+	assert.Equal(t, http.StatusNotFound, statusFor(missingErr))
+	assert.Equal(t, [][]string{{"a"}, {"missing"}}, log.batches)
+
+}
+
+func Test_newBatchers(t *testing.T) {
+
+	// setup - batchable types are found even when wrapped, and shared between routes
+	routes := []route{
+		{params: RouteParams{"a": testTypeBatched{}, "b": testTypeString("")}},
+		{params: RouteParams{"c": Cache(testTypeBatched{}, CacheOptions{})}},
+	}
+
+	// do
+	err := newBatchers(routes, 0, 0)
+
+	// check
+	assert.NoError(t, err)
+	if assert.Len(t, routes[0].batchers, 1) && assert.Len(t, routes[1].batchers, 1) {
+		b := routes[0].batchers[reflect.TypeOf(testTypeBatched{})]
+		assert.Same(t, b, routes[1].batchers[reflect.TypeOf(testTypeBatched{})], "routes should share a batcher")
+		assert.Equal(t, DefaultBatchWindow, b.window)
+		assert.Equal(t, DefaultMaxBatchSize, b.maxSize)
+	}
+
+}
+
+func Test_newBatchers_DifferentValues(t *testing.T) {
+
+	// setup
+	routes := []route{
+		{params: RouteParams{"a": testTypeBatched{}}},
+		{params: RouteParams{"b": testTypeBatched{value: "other"}}},
+	}
+
+	// do
+	err := newBatchers(routes, 0, 0)
+
+	// check
+	assert.NoError(t, err)
+	assert.NotSame(t, routes[0].batchers[reflect.TypeOf(testTypeBatched{})], routes[1].batchers[reflect.TypeOf(testTypeBatched{})])
+
+}
+
+func Test_newBatchers_DifferentValuesInOneRoute(t *testing.T) {
+
+	// setup
+	routes := []route{
+		{path: "/{a}/{b}", params: RouteParams{"a": testTypeBatched{}, "b": testTypeBatched{value: "other"}}},
+	}
+
+	// do
+	err := newBatchers(routes, 0, 0)
+
+	// check
+	assert.ErrorContains(t, err, "different values of orbit.testTypeBatched")
+
+}
+
+// A batchable param type holding a func, so it can't be compared with ==.
+type testTypeBatchedFunc struct {
+	load func([]string) map[string]any
+}
+
+func (x testTypeBatchedFunc) FromRequest(param string) (any, error) {
+	panic("FromRequest shouldn't be called when FromRequestBatch exists")
+}
+
+func (x testTypeBatchedFunc) FromRequestBatch(params []string) map[string]any {
+	return x.load(params)
+}
+
+func Test_Router_Bake_BatchedFuncField(t *testing.T) {
+
+	// setup - the same value, holding a func, on two routes
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+	loader := testTypeBatchedFunc{load: func(params []string) map[string]any { return nil }}
+	r := NewRouter()
+	r.Handle("/a/{x}", handler, nil, RouteParams{"x": loader}, nil)
+	r.Handle("/b/{x}", handler, nil, RouteParams{"x": loader}, nil)
+
+	// do
+	err := r.Bake()
+
+	// check
+	assert.NoError(t, err)
+
+}
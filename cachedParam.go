@@ -0,0 +1,264 @@
+package orbit
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures a CachedParam.
+type CacheOptions struct {
+	// How long a resolved value is cached for. Zero means values never expire
+	// (though they can still be evicted, see MaxEntries).
+	TTL time.Duration
+
+	// The most values to cache. When it's full, the least recently used value
+	// is evicted to make room. Zero means DefaultCacheMaxEntries, and a
+	// negative number means there's no limit (which lets clients fill your
+	// memory by asking for lots of things that don't exist, if NegativeTTL is
+	// set).
+	MaxEntries int
+
+	// How long to cache "not found" errors (errors with a 404 status, like
+	// NotFound) for, so lookups for things that don't exist don't hit your
+	// database every time either. Zero means they aren't cached. Other errors
+	// are never cached.
+	NegativeTTL time.Duration
+}
+
+// DefaultCacheMaxEntries is the most values a CachedParam holds, unless its
+// options say otherwise.
+const DefaultCacheMaxEntries = 10000
+
+// A CachedParam wraps a param type to cache what it resolves to across
+// requests, keyed by the raw value from the path. Make one with Cache, and use
+// it in RouteParams in place of the type it wraps:
+//
+//	users := orbit.Cache(User{}, orbit.CacheOptions{TTL: time.Minute, MaxEntries: 10000})
+//
+//	r.Handle("/user/{user}", handler, nil, orbit.RouteParams{"user": users}, nil)
+//
+// Handlers still get a User (not a CachedParam), so nothing else needs to
+// change. Keep hold of the CachedParam to Invalidate values when they change.
+//
+// If several requests need the same uncached value at once, it's only
+// resolved once, and they all get the result. Because of that, the wrapped
+// type's FromRequestContext gets a context that isn't cancelled if the
+// request that started the lookup is.
+type CachedParam struct {
+	param FromRequestable
+	opts  CacheOptions
+	now   func() time.Time // Swapped out in tests.
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element // Values of lru are *cachedValue.
+	lru      *list.List               // Most recently used at the front.
+	inFlight map[string]*cacheLookup
+}
+
+// A cachedValue is a cached result for a raw value.
+type cachedValue struct {
+	raw     string
+	value   any
+	err     error // Only ever a "not found" error.
+	expires time.Time
+}
+
+// A cacheLookup is a lookup that's in progress, for other requests needing the
+// same value to wait on.
+type cacheLookup struct {
+	done        chan struct{} // Closed once value and err are set.
+	value       any
+	err         error
+	invalidated bool // Set if Invalidate is called during the lookup, so the result isn't cached.
+}
+
+// Cache wraps param so the values it resolves to are cached across requests
+// (see CachedParam).
+//
+// It panics if param implements FromRequestWithParamsable, since the value
+// those resolve to depends on more than their raw value. It also panics if it
+// implements Releasable or io.Closer, since cached values would be released
+// at the end of the first request that used them.
+func Cache(param FromRequestable, opts CacheOptions) *CachedParam {
+
+	if _, ok := param.(FromRequestWithParamsable); ok {
+		panic(errMisconfigured(fmt.Sprintf("can't cache %T, since it uses FromRequestWithParams", param)))
+	}
+
+	switch param.(type) {
+	case Releasable, io.Closer:
+		panic(errMisconfigured(fmt.Sprintf("can't cache %T, since it needs releasing after each request", param)))
+	}
+
+	if opts.MaxEntries == 0 {
+		opts.MaxEntries = DefaultCacheMaxEntries
+	}
+
+	return &CachedParam{
+		param:    param,
+		opts:     opts,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inFlight: make(map[string]*cacheLookup),
+	}
+
+}
+
+func (c *CachedParam) unwrapParam() FromRequestable {
+	return c.param
+}
+
+// FromRequest resolves raw using the wrapped type, or the cache if it's there.
+func (c *CachedParam) FromRequest(raw string) (any, error) {
+	return c.FromRequestContext(context.Background(), raw)
+}
+
+// FromRequestContext is FromRequest, but passes ctx on to the wrapped type (if
+// it wants it).
+func (c *CachedParam) FromRequestContext(ctx context.Context, raw string) (any, error) {
+
+	c.mu.Lock()
+
+	if cached, ok := c.get(raw); ok {
+		c.mu.Unlock()
+		return cached.value, cached.err
+	}
+
+	// If someone's already looking this up, wait for them.
+	if lookup, ok := c.inFlight[raw]; ok {
+		c.mu.Unlock()
+		select {
+		case <-lookup.done:
+			return lookup.value, lookup.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	lookup := &cacheLookup{done: make(chan struct{})}
+	c.inFlight[raw] = lookup
+	c.mu.Unlock()
+
+	// Make sure waiters are let go, even if the lookup panics.
+	defer close(lookup.done)
+	defer func() {
+		c.mu.Lock()
+		delete(c.inFlight, raw)
+		c.mu.Unlock()
+	}()
+
+	// If the lookup panics this is what waiters get, since the panic itself
+	// only reaches this request.
+	lookup.err = errMisconfigured(fmt.Sprintf("looking up %s panicked", raw))
+	lookup.value, lookup.err = callFromRequest(context.WithoutCancel(ctx), c.param, raw, nil)
+
+	c.mu.Lock()
+	if !lookup.invalidated {
+		c.store(raw, lookup.value, lookup.err)
+	}
+	c.mu.Unlock()
+
+	return lookup.value, lookup.err
+
+}
+
+// get returns the cached result for raw, if there is one that hasn't expired.
+// Call it with mu locked.
+func (c *CachedParam) get(raw string) (*cachedValue, bool) {
+
+	el, ok := c.entries[raw]
+	if !ok {
+		return nil, false
+	}
+
+	cached := el.Value.(*cachedValue)
+	if !cached.expires.IsZero() && !c.now().Before(cached.expires) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return cached, true
+
+}
+
+// store caches the result of looking raw up, if it's cacheable, evicting the
+// least recently used value if the cache is full. Call it with mu locked.
+func (c *CachedParam) store(raw string, value any, err error) {
+
+	ttl := c.opts.TTL
+	if err != nil {
+		if c.opts.NegativeTTL <= 0 || statusFor(err) != http.StatusNotFound {
+			return
+		}
+		ttl = c.opts.NegativeTTL
+	}
+
+	cached := &cachedValue{raw: raw, value: value, err: err}
+	if ttl > 0 {
+		cached.expires = c.now().Add(ttl)
+	}
+
+	if el, ok := c.entries[raw]; ok {
+		c.remove(el)
+	}
+	c.entries[raw] = c.lru.PushFront(cached)
+
+	if c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+
+}
+
+// remove removes a cached value. Call it with mu locked.
+func (c *CachedParam) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cachedValue).raw)
+}
+
+// Invalidate removes the cached value for raw (the value from the path, e.g.
+// a user's ID), so it's looked up again next time it's needed. Call it when
+// the thing it represents changes.
+func (c *CachedParam) Invalidate(raw string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[raw]; ok {
+		c.remove(el)
+	}
+
+	// If it's being looked up right now, the result might be out of date.
+	if lookup, ok := c.inFlight[raw]; ok {
+		lookup.invalidated = true
+	}
+
+}
+
+// Purge removes every cached value.
+func (c *CachedParam) Purge() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	for _, lookup := range c.inFlight {
+		lookup.invalidated = true
+	}
+
+}
+
+// Len is how many values are cached, including ones that have expired but
+// haven't been removed yet.
+func (c *CachedParam) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,271 @@
+package orbit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A param type that counts lookups, and can be made to wait. "missing" isn't
+// found, and "broken" fails with a non-404.
+type testTypeLookup struct {
+	calls *atomic.Int32
+	gate  chan struct{} // If set, lookups wait for it to close.
+	value string
+}
+
+func (x testTypeLookup) FromRequest(param string) (any, error) {
+	x.calls.Add(1)
+	if x.gate != nil {
+		<-x.gate
+	}
+	switch param {
+	case "missing":
+		return nil, NotFound("no such thing")
+	case "broken":
+		return nil, InternalServerError("broken")
+	}
+	return testTypeLookup{calls: x.calls, value: param}, nil
+}
+
+// newTestCache returns a cached testTypeLookup, and a func to move its clock on.
+func newTestCache(opts CacheOptions) (*CachedParam, *atomic.Int32, func(time.Duration)) {
+	calls := &atomic.Int32{}
+	cache := Cache(testTypeLookup{calls: calls}, opts)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	return cache, calls, func(d time.Duration) { now = now.Add(d) }
+}
+
+func Test_CachedParam_TTL(t *testing.T) {
+
+	// setup
+	cache, calls, advance := newTestCache(CacheOptions{TTL: time.Minute})
+
+	// do / check
+	first, err := cache.FromRequest("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", first.(testTypeLookup).value)
+
+	second, _ := cache.FromRequest("a")
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), calls.Load())
+
+	advance(time.Minute)
+	_, _ = cache.FromRequest("a")
+	assert.Equal(t, int32(2), calls.Load(), "expired values should be looked up again")
+
+}
+
+func Test_CachedParam_LRU(t *testing.T) {
+
+	// setup
+	cache, calls, _ := newTestCache(CacheOptions{MaxEntries: 2})
+
+	// do - a is used more recently than b, so c evicts b
+	_, _ = cache.FromRequest("a")
+	_, _ = cache.FromRequest("b")
+	_, _ = cache.FromRequest("a")
+	_, _ = cache.FromRequest("c")
+
+	// check
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, int32(3), calls.Load())
+
+	_, _ = cache.FromRequest("a")
+	assert.Equal(t, int32(3), calls.Load(), "a should still be cached")
+	_, _ = cache.FromRequest("b")
+	assert.Equal(t, int32(4), calls.Load(), "b should have been evicted")
+
+}
+
+func Test_CachedParam_MaxEntriesDefault(t *testing.T) {
+
+	// setup
+	limited, _, _ := newTestCache(CacheOptions{})
+	unlimited, _, _ := newTestCache(CacheOptions{MaxEntries: -1})
+
+	// do
+	for i := 0; i < DefaultCacheMaxEntries+10; i++ {
+		_, _ = limited.FromRequest(strconv.Itoa(i))
+		_, _ = unlimited.FromRequest(strconv.Itoa(i))
+	}
+
+	// check
+	assert.Equal(t, DefaultCacheMaxEntries, limited.Len())
+	assert.Equal(t, DefaultCacheMaxEntries+10, unlimited.Len())
+
+}
+
+func Test_CachedParam_NegativeCaching(t *testing.T) {
+
+	// setup
+	cache, calls, advance := newTestCache(CacheOptions{TTL: time.Hour, NegativeTTL: time.Second})
+
+	// do / check - not found is cached, for NegativeTTL
+	_, err := cache.FromRequest("missing")
+	assert.Equal(t, http.StatusNotFound, statusFor(err))
+	_, err = cache.FromRequest("missing")
+	assert.Equal(t, http.StatusNotFound, statusFor(err))
+	assert.Equal(t, int32(1), calls.Load())
+
+	advance(time.Second)
+	_, _ = cache.FromRequest("missing")
+	assert.Equal(t, int32(2), calls.Load())
+
+	// do / check - other errors aren't
+	_, _ = cache.FromRequest("broken")
+	_, _ = cache.FromRequest("broken")
+	assert.Equal(t, int32(4), calls.Load())
+
+}
+
+func Test_CachedParam_NoNegativeCaching(t *testing.T) {
+
+	// setup
+	cache, calls, _ := newTestCache(CacheOptions{})
+
+	// do
+	_, _ = cache.FromRequest("missing")
+	_, _ = cache.FromRequest("missing")
+
+	// check
+	assert.Equal(t, int32(2), calls.Load())
+
+}
+
+func Test_CachedParam_Invalidate(t *testing.T) {
+
+	// setup
+	cache, calls, _ := newTestCache(CacheOptions{})
+	_, _ = cache.FromRequest("a")
+	_, _ = cache.FromRequest("b")
+
+	// do
+	cache.Invalidate("a")
+	_, _ = cache.FromRequest("a")
+	_, _ = cache.FromRequest("b")
+
+	// check
+	assert.Equal(t, int32(3), calls.Load())
+
+	// do - Purge removes everything
+	cache.Purge()
+	assert.Equal(t, 0, cache.Len())
+
+}
+
+func Test_CachedParam_Singleflight(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	gate := make(chan struct{})
+	cache := Cache(testTypeLookup{calls: calls, gate: gate}, CacheOptions{})
+
+	// do - lots of lookups for the same value at once
+	var wg sync.WaitGroup
+	results := make([]any, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.FromRequest("a")
+		}(i)
+	}
+
+	// Wait until the first lookup's started, and the rest are waiting on it.
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	// check
+	assert.Equal(t, int32(1), calls.Load())
+	for _, result := range results {
+		assert.Equal(t, "a", result.(testTypeLookup).value)
+	}
+
+}
+
+func Test_CachedParam_InvalidateDuringLookup(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	gate := make(chan struct{})
+	cache := Cache(testTypeLookup{calls: calls, gate: gate}, CacheOptions{})
+
+	// do - invalidate while the lookup is happening
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cache.FromRequest("a")
+	}()
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	cache.Invalidate("a")
+	close(gate)
+	<-done
+
+	// check - the possibly stale result wasn't cached
+	assert.Equal(t, 0, cache.Len())
+
+}
+
+func Test_CachedParam_WaiterCancelled(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	gate := make(chan struct{})
+	defer close(gate)
+	cache := Cache(testTypeLookup{calls: calls, gate: gate}, CacheOptions{})
+
+	go func() { _, _ = cache.FromRequest("a") }()
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// do
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cache.FromRequestContext(ctx, "a")
+
+	// check
+	assert.ErrorIs(t, err, context.Canceled)
+
+}
+
+func Test_Cache_Panics(t *testing.T) {
+	assert.Panics(t, func() { Cache(testTypeScoped{}, CacheOptions{}) })
+	assert.Panics(t, func() { Cache(testTypeReleasable{}, CacheOptions{}) })
+}
+
+func Test_Router_E2E_CachedParam(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	var got []any
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		got = append(got, params["thing"])
+	})
+
+	r := NewRouter()
+	r.Handle("/thing/{thing}", handler, nil, RouteParams{
+		"thing": Cache(testTypeLookup{calls: calls}, CacheOptions{}),
+	}, nil, Named("thing"))
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/thing/a", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/thing/a", nil))
+
+	// check - handlers get the wrapped type, and only the first request looked it up
+	assert.Equal(t, []any{testTypeLookup{calls: calls, value: "a"}, testTypeLookup{calls: calls, value: "a"}}, got)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// check - URLs are built from the wrapped type too
+	_, err := r.URL("thing", RouteParams{"thing": testTypeLookup{value: "b"}})
+	assert.ErrorContains(t, err, "ToRequestable", "the type check should pass, and fail on ToRequest")
+
+}
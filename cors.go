@@ -0,0 +1,227 @@
+package orbit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures Cross-Origin Resource Sharing, so browsers let pages
+// from other origins call your routes. Set Router.CORS to turn it on for every
+// route, and use the CORS route option to change it for one route.
+//
+// The router answers preflight (OPTIONS) requests itself, allowing the methods
+// registered for the route's path, unless you've registered a route that
+// handles OPTIONS for that path. Responses to other requests from allowed
+// origins get the Access-Control-Allow-Origin header (and friends), including
+// error responses, so the page can read them.
+type CORSOptions struct {
+	// Origins that can make requests, like "https://example.com". An origin
+	// can have one * wildcard, like "https://*.example.com", and "*" on its own
+	// allows any origin.
+	AllowedOrigins []string
+
+	// Called for origins that aren't in AllowedOrigins, to decide if they're
+	// allowed. Nil means they aren't.
+	AllowOriginFunc func(origin string) bool
+
+	// Headers requests can send. Nil means any the browser asks for.
+	AllowedHeaders []string
+
+	// Response headers the page can read, other than the ones it always can.
+	ExposedHeaders []string
+
+	// Whether requests can include credentials, like cookies. If they can,
+	// the request's origin is sent back in place of "*". It can't be used
+	// with an AllowedOrigins of "*", since that would let any site make
+	// requests as your users (the router won't bake if you try).
+	AllowCredentials bool
+
+	// How long browsers can cache the answer to a preflight request for.
+	// Zero leaves it up to the browser.
+	MaxAge time.Duration
+}
+
+// CORS overrides the router's CORS options for the route. Pass nil to turn
+// CORS off for just this route.
+func CORS(opts *CORSOptions) RouteOption {
+	return func(r *route) {
+		r.cors = opts
+		r.corsSet = true
+	}
+}
+
+// check returns an error if opts allow credentials from any origin. opts can
+// be nil.
+func (opts *CORSOptions) check() error {
+
+	if opts != nil && opts.AllowCredentials && contains(opts.AllowedOrigins, "*") {
+		return errMisconfigured("CORS can't allow credentials from any origin (\"*\"), list the origins instead")
+	}
+
+	return nil
+
+}
+
+// allows reports whether requests from origin are allowed.
+func (opts *CORSOptions) allows(origin string) bool {
+
+	for _, allowed := range opts.AllowedOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+
+	return opts.AllowOriginFunc != nil && opts.AllowOriginFunc(origin)
+
+}
+
+// matchOrigin reports whether origin matches allowed, which can have a *
+// wildcard in it.
+func matchOrigin(allowed string, origin string) bool {
+
+	if allowed == "*" {
+		return true
+	}
+
+	prefix, suffix, wildcard := strings.Cut(allowed, "*")
+	if !wildcard {
+		return strings.EqualFold(allowed, origin)
+	}
+
+	origin = strings.ToLower(origin)
+	prefix, suffix = strings.ToLower(prefix), strings.ToLower(suffix)
+
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, suffix)
+
+}
+
+// allowOrigin sets the headers saying requests from origin are allowed.
+func (opts *CORSOptions) allowOrigin(header http.Header, origin string) {
+
+	switch {
+	case opts.AllowCredentials:
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+	case contains(opts.AllowedOrigins, "*"):
+		header.Set("Access-Control-Allow-Origin", "*")
+	default:
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+
+}
+
+// decorate sets the CORS headers on the response to an actual (not
+// preflight) request.
+func (opts *CORSOptions) decorate(header http.Header, r *http.Request) {
+
+	header.Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !opts.allows(origin) {
+		return
+	}
+
+	opts.allowOrigin(header, origin)
+	if len(opts.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+	}
+
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// servePreflight answers a CORS preflight request, allowing the methods
+// registered for routes matching its path. The options of the route that
+// would handle the requested method are used.
+//
+// It returns false without responding if the router shouldn't answer it:
+// if no route matching the path has CORS turned on, or one handles OPTIONS
+// itself.
+func (router Router) servePreflight(w http.ResponseWriter, r *http.Request) bool {
+
+	requested := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+
+	var chosen, first *route
+	var methods []string
+	anyMethod := false
+
+	for i := range router.routes {
+		route := &router.routes[i]
+
+		if _, err := tokenise(&route.regex, route.orderedParamNames, r.URL.Path); err != nil {
+			continue
+		}
+		if contains(route.methods, http.MethodOptions) {
+			return false
+		}
+		if route.cors == nil {
+			continue
+		}
+
+		if first == nil {
+			first = route
+		}
+		if len(route.methods) == 0 {
+			anyMethod = true
+		}
+		for _, method := range route.methods {
+			if method = strings.ToUpper(method); !contains(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+		if chosen == nil && (len(route.methods) == 0 || contains(route.methods, requested)) {
+			chosen = route
+		}
+	}
+
+	if first == nil {
+		return false
+	}
+
+	// If no route handles the requested method, answer anyway, so the
+	// browser can see which methods are allowed.
+	if chosen == nil {
+		chosen = first
+	}
+	opts := chosen.cors
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	if origin := r.Header.Get("Origin"); opts.allows(origin) {
+
+		opts.allowOrigin(header, origin)
+
+		if anyMethod {
+			header.Set("Access-Control-Allow-Methods", requested)
+		} else {
+			header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		}
+
+		if opts.AllowedHeaders != nil {
+			header.Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+		} else if requestedHeaders := r.Header.Get("Access-Control-Request-Headers"); requestedHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", requestedHeaders)
+		}
+
+		if opts.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(ceilSeconds(opts.MaxAge)))
+		}
+
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return true
+
+}
@@ -0,0 +1,292 @@
+package orbit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newCORSRouter makes a router with CORS on, and a few routes for
+// /widgets/{widget}.
+func newCORSRouter(opts *CORSOptions, routeOptions ...RouteOption) Router {
+
+	ok := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r := NewRouter()
+	r.CORS = opts
+	r.Handle("/widgets/{widget}", ok, []string{"GET"}, RouteParams{"widget": testTypeString("")}, nil)
+	r.Handle("/widgets/{widget}", ok, []string{"PUT", "DELETE"}, RouteParams{"widget": testTypeString("")}, nil, routeOptions...)
+
+	return r
+
+}
+
+// preflight makes a preflight request.
+func preflight(path string, origin string, method string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	return req
+}
+
+func Test_Router_CORS_Preflight(t *testing.T) {
+
+	// setup
+	r := newCORSRouter(&CORSOptions{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	})
+	req := preflight("/widgets/1", "https://example.com", "PUT")
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// check
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+}
+
+func Test_Router_CORS_Preflight_EchoesRequestedHeaders(t *testing.T) {
+
+	// setup
+	r := newCORSRouter(&CORSOptions{AllowedOrigins: []string{"*"}})
+	req := preflight("/widgets/1", "https://anywhere.com", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "X-Custom")
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// check
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Custom", w.Header().Get("Access-Control-Allow-Headers"))
+
+}
+
+func Test_Router_CORS_Preflight_NotAllowed(t *testing.T) {
+
+	// setup
+	r := newCORSRouter(&CORSOptions{AllowedOrigins: []string{"https://example.com"}})
+	req := preflight("/widgets/1", "https://evil.com", "PUT")
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// check
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+
+}
+
+func Test_Router_CORS_Preflight_UnknownPath(t *testing.T) {
+
+	// setup
+	r := newCORSRouter(&CORSOptions{AllowedOrigins: []string{"*"}})
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, preflight("/gadgets/1", "https://example.com", "GET"))
+
+	// check
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+}
+
+func Test_Router_CORS_Preflight_RouteHandlesOptions(t *testing.T) {
+
+	// setup
+	called := false
+	r := NewRouter()
+	r.CORS = &CORSOptions{AllowedOrigins: []string{"*"}}
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}), []string{"OPTIONS"}, nil, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, preflight("/a", "https://example.com", "GET"))
+
+	// check
+	assert.True(t, called, "route handling OPTIONS should've been called")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+}
+
+func Test_Router_CORS_Decorates(t *testing.T) {
+
+	// setup
+	r := newCORSRouter(&CORSOptions{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		ExposedHeaders:   []string{"X-Total"},
+		AllowCredentials: true,
+	})
+
+	testCases := []struct {
+		name         string
+		path         string
+		origin       string
+		expectOrigin string
+		expectStatus int
+	}{
+		{name: "wildcard origin", path: "/widgets/1", origin: "https://app.example.com", expectOrigin: "https://app.example.com", expectStatus: http.StatusOK},
+		{name: "wildcard needs something to match", path: "/widgets/1", origin: "https://.example.com", expectOrigin: "", expectStatus: http.StatusOK},
+		{name: "other origin", path: "/widgets/1", origin: "https://example.org", expectOrigin: "", expectStatus: http.StatusOK},
+		{name: "no origin", path: "/widgets/1", origin: "", expectOrigin: "", expectStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// setup
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+
+			// do
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			// check
+			assert.Equal(t, tc.expectStatus, w.Code)
+			assert.Equal(t, tc.expectOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Contains(t, w.Header().Values("Vary"), "Origin")
+			if tc.expectOrigin != "" {
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+				assert.Equal(t, "X-Total", w.Header().Get("Access-Control-Expose-Headers"))
+			}
+
+		})
+	}
+
+}
+
+func Test_Router_CORS_ErrorResponse(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.CORS = &CORSOptions{AllowedOrigins: []string{"https://example.com"}}
+	r.Handle("/{n}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Error("handler shouldn't be called")
+	}), nil, RouteParams{"n": testTypeInt(0)}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/notanumber", nil)
+	req.Header.Set("Origin", "https://example.com")
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"), "errors should be readable too")
+
+}
+
+func Test_Router_CORS_RouteOverride(t *testing.T) {
+
+	testCases := []struct {
+		name         string
+		override     *CORSOptions
+		expectOrigin string
+	}{
+		{name: "own options", override: &CORSOptions{AllowOriginFunc: func(o string) bool { return o == "https://other.com" }}, expectOrigin: "https://other.com"},
+		{name: "turned off", override: nil, expectOrigin: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// setup
+			r := newCORSRouter(&CORSOptions{AllowedOrigins: []string{"https://example.com"}}, CORS(tc.override))
+			req := httptest.NewRequest(http.MethodPut, "/widgets/1", nil)
+			req.Header.Set("Origin", "https://other.com")
+
+			// do
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			// check
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.expectOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+
+		})
+	}
+
+}
+
+func Test_Router_CORS_Preflight_RouteOverride(t *testing.T) {
+
+	// setup
+	r := newCORSRouter(
+		&CORSOptions{AllowedOrigins: []string{"https://example.com"}},
+		CORS(&CORSOptions{AllowedOrigins: []string{"https://other.com"}}),
+	)
+
+	// do
+	wPut := httptest.NewRecorder()
+	r.ServeHTTP(wPut, preflight("/widgets/1", "https://other.com", "PUT"))
+	wGet := httptest.NewRecorder()
+	r.ServeHTTP(wGet, preflight("/widgets/1", "https://other.com", "GET"))
+
+	// check
+	assert.Equal(t, "https://other.com", wPut.Header().Get("Access-Control-Allow-Origin"), "PUT route's options should be used")
+	assert.Empty(t, wGet.Header().Get("Access-Control-Allow-Origin"), "GET route's options should be used")
+
+}
+
+func Test_Router_CORS_CredentialsFromAnyOrigin(t *testing.T) {
+
+	// setup
+	r := newCORSRouter(&CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+
+	// do
+	err := r.Bake()
+
+	// check
+	assert.ErrorAs(t, err, new(errMisconfigured))
+
+}
+
+func Test_matchOrigin(t *testing.T) {
+
+	testCases := []struct {
+		allowed string
+		origin  string
+		expect  bool
+	}{
+		{allowed: "*", origin: "https://a.com", expect: true},
+		{allowed: "https://a.com", origin: "https://a.com", expect: true},
+		{allowed: "https://a.com", origin: "HTTPS://A.COM", expect: true},
+		{allowed: "https://a.com", origin: "https://b.com", expect: false},
+		{allowed: "https://*.a.com", origin: "https://x.a.com", expect: true},
+		{allowed: "https://*.a.com", origin: "https://a.com", expect: false},
+		{allowed: "https://*.a.com", origin: "http://x.a.com", expect: false},
+		{allowed: "https://*.a.com", origin: "https://x.a.com.evil.com", expect: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.allowed+" "+tc.origin, func(t *testing.T) {
+
+			// do, check
+			assert.Equal(t, tc.expect, matchOrigin(tc.allowed, tc.origin))
+
+		})
+	}
+
+}
@@ -1,6 +1,11 @@
 package orbit
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
 type errRouteDoesNotMatch string
 
@@ -14,11 +19,172 @@ func (e errMisconfigured) Error() string {
 	return fmt.Sprintf("orbit may be misconfigured: %s", string(e))
 }
 
-type errCoudlntGetParams struct {
-	paramName string
-	err       error
+// A ParamError describes a param that couldn't be resolved from a request.
+type ParamError struct {
+	Name  string // The param's name, from the route's path.
+	Value string // The raw value of the param in the request.
+	Err   error  // Why it couldn't be resolved (usually from FromRequest).
 }
 
-func (e errCoudlntGetParams) Error() string {
-	return fmt.Sprintf("couldn't get %s from request (%s)", e.paramName, e.err.Error())
+func (e ParamError) Error() string {
+	return fmt.Sprintf("couldn't get %s from request (%s)", e.Name, e.Err.Error())
+}
+
+func (e ParamError) Unwrap() error {
+	return e.Err
+}
+
+// ParamErrors is every param that failed to resolve for a request, in the
+// order the params appear in the route's path. It's what Orbit reports when
+// params fail, so clients get the whole story rather than just the first
+// problem.
+//
+// Use errors.As to get at it if you want to build your own validation report.
+type ParamErrors []ParamError
+
+func (e ParamErrors) Error() string {
+	messages := make([]string, len(e))
+	for idx, paramErr := range e {
+		messages[idx] = paramErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns each of the individual ParamErrors.
+func (e ParamErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for idx, paramErr := range e {
+		errs[idx] = paramErr
+	}
+	return errs
+}
+
+// StatusCode returns the worst (highest) status of the param errors (see
+// statusFor). So if any failed with a server error, or an error without a
+// status, the whole lot is reported as a server error rather than passing as
+// the client's fault.
+func (e ParamErrors) StatusCode() int {
+	worst := 0
+	for _, paramErr := range e {
+		if status := statusFor(paramErr); status > worst {
+			worst = status
+		}
+	}
+	return worst
+}
+
+// clientMessage is what to tell the client about the failed params: only the
+// ones that failed with a client error (4xx), since the rest might be internal
+// details (like a database error). They're still logged in full.
+func (e ParamErrors) clientMessage() string {
+	var messages []string
+	for _, paramErr := range e {
+		if status := statusFor(paramErr); status >= 400 && status < 500 {
+			messages = append(messages, paramErr.Error())
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// errPanic is a panic that Orbit recovered from, reported as a 500.
+type errPanic struct {
+	value any    // What was passed to panic.
+	stack []byte // The stack trace from where it panicked.
+}
+
+func (e errPanic) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+func (e errPanic) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// An Error is an error with a HTTP status code attached, and a message that's
+// safe to show to whoever made the request.
+//
+// Return one from your FromRequest or FromBody methods (or wrap one with
+// fmt.Errorf's %w) and Orbit will respond with that status code instead of
+// the default 503. For 4xx statuses the error's message is sent as the body.
+type Error struct {
+	Status  int    // The HTTP status code to respond with.
+	Message string // A message describing what went wrong, for the client.
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the HTTP status code the error should be reported with.
+func (e Error) StatusCode() int {
+	return e.Status
+}
+
+// BadRequest returns an Error that'll be reported as a 400 Bad Request.
+func BadRequest(message string) Error {
+	return Error{Status: http.StatusBadRequest, Message: message}
+}
+
+// Unauthorized returns an Error that'll be reported as a 401 Unauthorized.
+func Unauthorized(message string) Error {
+	return Error{Status: http.StatusUnauthorized, Message: message}
+}
+
+// Forbidden returns an Error that'll be reported as a 403 Forbidden.
+func Forbidden(message string) Error {
+	return Error{Status: http.StatusForbidden, Message: message}
+}
+
+// NotFound returns an Error that'll be reported as a 404 Not Found.
+func NotFound(message string) Error {
+	return Error{Status: http.StatusNotFound, Message: message}
+}
+
+// Conflict returns an Error that'll be reported as a 409 Conflict.
+func Conflict(message string) Error {
+	return Error{Status: http.StatusConflict, Message: message}
+}
+
+// Unprocessable returns an Error that'll be reported as a 422 Unprocessable
+// Entity.
+func Unprocessable(message string) Error {
+	return Error{Status: http.StatusUnprocessableEntity, Message: message}
+}
+
+// InternalServerError returns an Error that'll be reported as a 500 Internal
+// Server Error. The message is logged, but not sent to the client.
+func InternalServerError(message string) Error {
+	return Error{Status: http.StatusInternalServerError, Message: message}
+}
+
+// A statusCoder is an error that knows which HTTP status it should be reported
+// with, like Error.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// clientMessager is implemented by errors that only want some of their
+// message showing to the client, like ParamErrors.
+type clientMessager interface {
+	clientMessage() string
+}
+
+// headerSetter is implemented by errors that need extra headers sending with
+// their response, like Retry-After.
+type headerSetter interface {
+	setHeaders(http.Header)
+}
+
+// statusFor works out the HTTP status to respond with for an error. If the
+// error (or anything it wraps) is a statusCoder then its status is used,
+// otherwise it's a 503.
+func statusFor(err error) int {
+
+	var sc statusCoder
+	if errors.As(err, &sc) && sc.StatusCode() != 0 {
+		return sc.StatusCode()
+	}
+
+	return http.StatusServiceUnavailable
+
 }
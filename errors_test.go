@@ -2,6 +2,9 @@ package orbit
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,15 +30,137 @@ func Test_errMisconfigured_Error(t *testing.T) {
 
 }
 
-func Test_errCoudlntGetParams_Error(t *testing.T) {
+func Test_ParamError_Error(t *testing.T) {
 
 	assert.Equal(
 		t,
 		"couldn't get paramname from request (example details)",
-		errCoudlntGetParams{
-			paramName: "paramname",
-			err:       errors.New("example details"),
+		ParamError{
+			Name:  "paramname",
+			Value: "value",
+			Err:   errors.New("example details"),
 		}.Error(),
 	)
 
 }
+
+func Test_ParamErrors_Error(t *testing.T) {
+
+	assert.Equal(
+		t,
+		"couldn't get a from request (first); couldn't get b from request (second)",
+		ParamErrors{
+			{Name: "a", Value: "1", Err: errors.New("first")},
+			{Name: "b", Value: "2", Err: errors.New("second")},
+		}.Error(),
+	)
+
+}
+
+func Test_ParamErrors_clientMessage(t *testing.T) {
+
+	assert.Equal(
+		t,
+		"couldn't get b from request (second); couldn't get c from request (third)",
+		ParamErrors{
+			{Name: "a", Err: errors.New("db: connection refused")},
+			{Name: "b", Err: NotFound("second")},
+			{Name: "c", Err: BadRequest("third")},
+			{Name: "d", Err: Error{Status: 500, Message: "fourth"}},
+		}.clientMessage(),
+	)
+
+}
+
+func Test_writeErrorResponse_HidesInternalParamErrors(t *testing.T) {
+
+	// setup
+	err := ParamErrors{
+		{Name: "a", Err: BadRequest("not a number")},
+		{Name: "b", Err: errors.New("db: connection refused")},
+	}
+
+	// do
+	w := httptest.NewRecorder()
+	writeErrorResponse(w, err)
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotContains(t, w.Body.String(), "db: connection refused")
+
+}
+
+func Test_ParamErrors_StatusCode(t *testing.T) {
+
+	assert.Equal(t, 503, ParamErrors{
+		{Name: "a", Err: errors.New("first")},
+	}.StatusCode())
+
+	assert.Equal(t, 404, ParamErrors{
+		{Name: "a", Err: BadRequest("first")},
+		{Name: "b", Err: Error{Status: 404, Message: "second"}},
+		{Name: "c", Err: BadRequest("third")},
+	}.StatusCode())
+
+	assert.Equal(t, 503, ParamErrors{
+		{Name: "a", Err: errors.New("first")},
+		{Name: "b", Err: BadRequest("second")},
+	}.StatusCode(), "errors without a status should win over client errors")
+
+	assert.Equal(t, 500, ParamErrors{
+		{Name: "a", Err: BadRequest("first")},
+		{Name: "b", Err: Error{Status: 500, Message: "second"}},
+	}.StatusCode(), "server errors should win over client errors")
+
+}
+
+func Test_Error_Error(t *testing.T) {
+
+	err := Error{Status: 418, Message: "example details"}
+
+	assert.Equal(t, "example details", err.Error())
+	assert.Equal(t, 418, err.StatusCode())
+
+}
+
+func Test_statusFor(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "plain", err: errors.New("oops"), want: 503},
+		{name: "bad_request", err: BadRequest("oops"), want: 400},
+		{name: "wrapped", err: fmt.Errorf("wrapped: %w", BadRequest("oops")), want: 400},
+		{name: "param", err: ParamError{Name: "foo", Err: BadRequest("oops")}, want: 400},
+		{name: "params", err: ParamErrors{{Name: "foo", Err: BadRequest("oops")}}, want: 400},
+		{name: "no_status", err: Error{Message: "oops"}, want: 503},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statusFor(tt.err))
+		})
+	}
+}
+
+func Test_ErrorConstructors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  Error
+		want int
+	}{
+		{name: "bad_request", err: BadRequest("x"), want: 400},
+		{name: "unauthorized", err: Unauthorized("x"), want: 401},
+		{name: "forbidden", err: Forbidden("x"), want: 403},
+		{name: "not_found", err: NotFound("x"), want: 404},
+		{name: "conflict", err: Conflict("x"), want: 409},
+		{name: "unprocessable", err: Unprocessable("x"), want: 422},
+		{name: "internal", err: InternalServerError("x"), want: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.err.StatusCode())
+			assert.Equal(t, "x", tt.err.Error())
+		})
+	}
+}
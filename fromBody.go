@@ -3,6 +3,7 @@ package orbit
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"reflect"
 )
 
@@ -27,10 +28,34 @@ type FromBodyable interface {
 	FromBody(io.ReadCloser) (any, error)
 }
 
-func tryFromBody(bodyType FromBodyable, body io.ReadCloser) (FromBodyable, error) {
+// The FromBodyRequestable interface is an optional extra for body types that
+// need more than just the body, like its Content-Type header, or that want to
+// stream the body rather than have it all read into memory first.
+//
+// If a body type implements it, Orbit calls FromBodyRequest instead of
+// FromBody, passing it the request. Orbit doesn't read or buffer the body
+// first, so it's up to you to read it from the request. Types still need a
+// FromBody method to be used as a body type, but it won't be called by the
+// router.
+//
+// MultipartForm is an example of a type that uses this.
+type FromBodyRequestable interface {
+	FromBodyRequest(*http.Request) (any, error)
+}
 
-	var resultMap map[string]FromBodyable = map[string]FromBodyable{"result": bodyType}
-	rResultMap := reflect.ValueOf(resultMap)
+// The FromBodyWithStateable interface is an optional extra for body types that
+// need your app's dependencies to decode themselves (see Router.Manage). It's
+// the body equivalent of FromRequestWithStateable.
+//
+// If a body type implements it, Orbit calls FromBodyWithState instead of
+// FromBody, passing it the router's State as well as the body. Types still
+// need a FromBody method to be used as a body type, but it won't be called by
+// the router.
+type FromBodyWithStateable interface {
+	FromBodyWithState(State, io.ReadCloser) (any, error)
+}
+
+func tryFromBody(bodyType FromBodyable, body io.ReadCloser) (FromBodyable, error) {
 
 	// Try decoding the body (as 'any' type) by calling the type's FromBody.
 	decodedBodyAsAny, err := bodyType.FromBody(body)
@@ -38,15 +63,55 @@ func tryFromBody(bodyType FromBodyable, body io.ReadCloser) (FromBodyable, error
 		return nil, err
 	}
 
+	return checkDecodedBody(bodyType, decodedBodyAsAny)
+
+}
+
+// tryFromBodyRequest is tryFromBody for FromBodyRequestable body types.
+func tryFromBodyRequest(bodyType FromBodyRequestable, req *http.Request) (FromBodyable, error) {
+
+	decodedBodyAsAny, err := bodyType.FromBodyRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkDecodedBody(bodyType.(FromBodyable), decodedBodyAsAny)
+
+}
+
+// tryFromBodyWithState is tryFromBody for FromBodyWithStateable body types.
+func tryFromBodyWithState(bodyType FromBodyWithStateable, state State, body io.ReadCloser) (FromBodyable, error) {
+
+	decodedBodyAsAny, err := bodyType.FromBodyWithState(state, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkDecodedBody(bodyType.(FromBodyable), decodedBodyAsAny)
+
+}
+
+// checkDecodedBody checks that the value a body type decoded is the same type
+// as the body type, and that it's valid (see Validatable).
+func checkDecodedBody(bodyType FromBodyable, decodedBodyAsAny any) (FromBodyable, error) {
+
+	var resultMap map[string]FromBodyable = map[string]FromBodyable{"result": bodyType}
+	rResultMap := reflect.ValueOf(resultMap)
+
 	// Decoded body currently has type 'any'. Use reflection to check it's
 	// actually of the correct type...
 	rExpectedType := reflect.ValueOf(bodyType)
 	rDecodedBodyAsAny := reflect.ValueOf(decodedBodyAsAny)
-	if rDecodedBodyAsAny.Type() != rExpectedType.Type() {
-		return nil, errMisconfigured(fmt.Sprintf("FromBody method returned unexpected type (want %s got %s)", rExpectedType.Type(), rDecodedBodyAsAny.Type()))
+	if !rDecodedBodyAsAny.IsValid() || rDecodedBodyAsAny.Type() != rExpectedType.Type() {
+		return nil, errMisconfigured(fmt.Sprintf("FromBody method returned unexpected type (want %s got %T)", rExpectedType.Type(), decodedBodyAsAny))
 	}
 	rResultMap.SetMapIndex(reflect.ValueOf("result"), rDecodedBodyAsAny)
 
+	// If the body type knows how to validate itself, check it's valid.
+	if err := validate(decodedBodyAsAny); err != nil {
+		return nil, err
+	}
+
 	return resultMap["result"], nil
 
 }
@@ -1,5 +1,7 @@
 package orbit
 
+import "context"
+
 // The FromRequestable interface allows Orbit to resolve your type from a url
 // param (by calling your type\s FromBody function, and passing it the param's
 // value as as string).
@@ -26,3 +28,72 @@ package orbit
 type FromRequestable interface {
 	FromRequest(string) (any, error)
 }
+
+// The FromRequestContextable interface is an optional extra for param types
+// that need the request's context, e.g. to pass it on to database calls so
+// they're cancelled if the request is.
+//
+// If a param type implements it, Orbit calls FromRequestContext instead of
+// FromRequest. Types still need a FromRequest method to be used in RouteParams,
+// but it won't be called by the router.
+//
+// The context also lets you get at params that have already been resolved for
+// this request with ResolveParam. For example, if {project} depends on {org}
+// (see ParamDependsOn):
+//
+//	func (p Project) FromRequestContext(ctx context.Context, slug string) (any, error) {
+//		org, err := orbit.ResolveParam(ctx, "org", Org{})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return db.GetProject(ctx, org.(Org).ID, slug)
+//	}
+type FromRequestContextable interface {
+	FromRequestContext(context.Context, string) (any, error)
+}
+
+// The FromRequestWithParamsable interface is an optional extra for param
+// types that depend on the params before them in the path. For example, with
+// /org/{org}/repo/{repo}, a Repo can only be looked up (or checked to belong
+// to the right org) once the Org is known.
+//
+// If a param type implements it, Orbit calls FromRequestWithParams instead of
+// FromRequest or FromRequestContext. Alongside the raw value, it's passed the
+// params that appear before it in the path, already resolved:
+//
+//	func (r Repo) FromRequestWithParams(slug string, resolved orbit.RouteParams) (any, error) {
+//		org := resolved["org"].(Org)
+//		return db.GetRepo(org.ID, slug)
+//	}
+//
+// Params like this always wait for the ones before them, even when the route
+// uses ParallelParams. If any of those fail, FromRequestWithParams isn't called.
+// Types still need a FromRequest method to be used in RouteParams, but it
+// won't be called by the router.
+type FromRequestWithParamsable interface {
+	FromRequestWithParams(string, RouteParams) (any, error)
+}
+
+// The FromRequestWithStateable interface is an optional extra for param types
+// that need your app's dependencies, like a database pool, to resolve
+// themselves (see Router.Manage).
+//
+// If a param type implements it, Orbit calls FromRequestWithState instead of
+// FromRequest or FromRequestContext, passing it the router's State along with
+// the context (as FromRequestContext would get it, so lookups can respect the
+// request's deadlines and use ResolveParam):
+//
+//	func (u User) FromRequestWithState(ctx context.Context, state orbit.State, uid string) (any, error) {
+//		users, err := orbit.Managed[UserStore](state)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return users.Get(ctx, uid)
+//	}
+//
+// Since the State is passed in, you can test it with a fake UserStore (see
+// NewState). Types still need a FromRequest method to be used in RouteParams,
+// but it won't be called by the router.
+type FromRequestWithStateable interface {
+	FromRequestWithState(context.Context, State, string) (any, error)
+}
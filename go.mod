@@ -1,6 +1,6 @@
 module github.com/mrbran4/orbit
 
-go 1.19
+go 1.21
 
 require github.com/stretchr/testify v1.8.1
 
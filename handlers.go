@@ -23,3 +23,45 @@ type HandlerFunc func(http.ResponseWriter, *http.Request, RouteParams, FromBodya
 func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request, p RouteParams, b FromBodyable) {
 	f(w, r, p, b)
 }
+
+// An ErrorReturningHandler is a Handler that can return an error instead of
+// writing an error response itself. When Orbit calls a handler that implements
+// it, it calls ServeHTTPWithError instead of ServeHTTP, and responds to any
+// error the same way it does to errors from FromRequest and FromBody. That
+// means returning an Error (e.g. orbit.NotFound("no such event")) sends its
+// status and message to the client, and anything else is a 503.
+//
+// Handlers still need a ServeHTTP method to be passed to Router.Handle.
+// ErrorHandlerFunc and ResponderFunc have both.
+type ErrorReturningHandler interface {
+	ServeHTTPWithError(http.ResponseWriter, *http.Request, RouteParams, FromBodyable) error
+}
+
+// ErrorHandlerFunc is like HandlerFunc, but for functions that return an
+// error. For example:
+//
+//	orbit.ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, p orbit.RouteParams, b orbit.FromBodyable) error {
+//		event, ok := events[p["event"].(orbit.BasicString)]
+//		if !ok {
+//			return orbit.NotFound("no such event")
+//		}
+//		return orbit.JSON(w, 200, event)
+//	})
+//
+// If f returns an error, it shouldn't have written anything to w, so that
+// Orbit can respond to the error.
+type ErrorHandlerFunc func(http.ResponseWriter, *http.Request, RouteParams, FromBodyable) error
+
+// ServeHTTPWithError calls f(w, r, p, b).
+func (f ErrorHandlerFunc) ServeHTTPWithError(w http.ResponseWriter, r *http.Request, p RouteParams, b FromBodyable) error {
+	return f(w, r, p, b)
+}
+
+// ServeHTTP calls f(w, r, p, b), and responds to its error if it returns one.
+// Orbit calls ServeHTTPWithError instead, but this lets ErrorHandlerFunc be
+// used as a Handler.
+func (f ErrorHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request, p RouteParams, b FromBodyable) {
+	if err := f(w, r, p, b); err != nil {
+		writeErrorResponse(w, err)
+	}
+}
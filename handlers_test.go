@@ -0,0 +1,120 @@
+package orbit
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Router_E2E_ErrorHandlerFunc(t *testing.T) {
+
+	handler := ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+		switch params["event"].(BasicString) {
+		case "missing":
+			return NotFound("no such event")
+		case "broken":
+			return errors.New("secret internal details")
+		case "crashed":
+			return InternalServerError("secret internal details")
+		}
+		w.WriteHeader(200)
+		return nil
+	})
+
+	r := NewRouter()
+	r.Handle("/events/{event}", handler, nil, RouteParams{"event": BasicString("")}, nil)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "ok", path: "/events/party", wantStatus: 200, wantBody: ""},
+		{name: "not_found", path: "/events/missing", wantStatus: 404, wantBody: "no such event\n"},
+		{name: "plain_error", path: "/events/broken", wantStatus: 503, wantBody: ""},
+		{name: "server_error", path: "/events/crashed", wantStatus: 500, wantBody: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+
+		})
+	}
+
+}
+
+func Test_Router_E2E_ErrorHandlerFunc_AfterWriting(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		options []RouteOption
+	}{
+		{name: "no_timeout"},
+		{name: "timeout", options: []RouteOption{Timeout(time.Second)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// setup
+			var logs bytes.Buffer
+			r := NewRouter()
+			r.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+			r.Handle("/a", ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+				w.Write([]byte("partial"))
+				return BadRequest("too late to say so")
+			}), nil, nil, nil, tt.options...)
+
+			// do
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+			// check - the response has started, so the error's only logged
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, "partial", w.Body.String())
+			assert.Contains(t, logs.String(), "too late to say so")
+
+		})
+	}
+
+}
+
+func Test_ErrorHandlerFunc_ServeHTTP(t *testing.T) {
+
+	// Called directly (not through a router), errors should still be responded to
+	handler := ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+		return Conflict("already exists")
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+
+	assert.Equal(t, 409, w.Code)
+	assert.Equal(t, "already exists\n", w.Body.String())
+
+}
+
+func Test_HandlerFunc_ServeHTTP(t *testing.T) {
+
+	called := false
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		called = true
+	})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+
+	assert.True(t, called)
+
+}
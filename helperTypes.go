@@ -1,10 +1,19 @@
 package orbit
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // This file contains some helper types for decoding common things from params.
+//
+// Their errors are all Errors with a 400 status, and messages that are fine
+// to show to whoever made the request.
 
 // BasicString is FromRequestable string type.
 // You can use it to extract string values from url params.
@@ -17,6 +26,11 @@ func (x BasicString) FromRequest(param string) (any, error) {
 	return val, nil
 }
 
+// ToRequest returns the BasicString as it would appear in a url param.
+func (x BasicString) ToRequest() string {
+	return string(x)
+}
+
 // BasicInt is FromRequestable int type.
 // You can use it to extract int values from url params.
 type BasicInt int
@@ -26,9 +40,315 @@ type BasicInt int
 func (x BasicInt) FromRequest(param string) (any, error) {
 	intval, err := strconv.Atoi(param)
 	if err != nil {
-		return nil, err
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a whole number", param))
 	}
 
 	result := BasicInt(intval)
 	return result, nil
 }
+
+// ToRequest returns the BasicInt as it would appear in a url param.
+func (x BasicInt) ToRequest() string {
+	return strconv.Itoa(int(x))
+}
+
+// BasicInt64 is FromRequestable int64 type.
+// You can use it to extract int64 values from url params.
+type BasicInt64 int64
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// BasicInt64 from it. If param is not a valid int64, it returns an error.
+func (x BasicInt64) FromRequest(param string) (any, error) {
+	intval, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a whole number", param))
+	}
+
+	return BasicInt64(intval), nil
+}
+
+// ToRequest returns the BasicInt64 as it would appear in a url param.
+func (x BasicInt64) ToRequest() string {
+	return strconv.FormatInt(int64(x), 10)
+}
+
+// BasicUint is FromRequestable uint type.
+// You can use it to extract non-negative int values from url params.
+type BasicUint uint
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// BasicUint from it. If param is not a valid uint, it returns an error.
+func (x BasicUint) FromRequest(param string) (any, error) {
+	uintval, err := strconv.ParseUint(param, 10, strconv.IntSize)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a positive whole number", param))
+	}
+
+	return BasicUint(uintval), nil
+}
+
+// ToRequest returns the BasicUint as it would appear in a url param.
+func (x BasicUint) ToRequest() string {
+	return strconv.FormatUint(uint64(x), 10)
+}
+
+// BasicFloat is FromRequestable float64 type.
+// You can use it to extract decimal values (like 1.5) from url params, on
+// routes with WideParams (since they contain a .).
+type BasicFloat float64
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// BasicFloat from it. If param is not a valid, finite number, it returns an
+// error.
+func (x BasicFloat) FromRequest(param string) (any, error) {
+	floatval, err := strconv.ParseFloat(param, 64)
+	if err != nil || math.IsNaN(floatval) || math.IsInf(floatval, 0) {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a number", param))
+	}
+
+	return BasicFloat(floatval), nil
+}
+
+// ToRequest returns the BasicFloat as it would appear in a url param.
+func (x BasicFloat) ToRequest() string {
+	return strconv.FormatFloat(float64(x), 'f', -1, 64)
+}
+
+// BasicBool is FromRequestable bool type.
+// It accepts the same values as strconv.ParseBool (true, false, 1, 0, t, f...)
+type BasicBool bool
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// BasicBool from it. If param is not a valid bool, it returns an error.
+func (x BasicBool) FromRequest(param string) (any, error) {
+	boolval, err := strconv.ParseBool(param)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't true or false", param))
+	}
+
+	return BasicBool(boolval), nil
+}
+
+// ToRequest returns the BasicBool as it would appear in a url param.
+func (x BasicBool) ToRequest() string {
+	return strconv.FormatBool(bool(x))
+}
+
+// UUID is a FromRequestable UUID, in the usual 8-4-4-4-12 hex format
+// (e.g. 123e4567-e89b-12d3-a456-426614174000). Upper and lower case are both
+// accepted.
+type UUID [16]byte
+
+// FromRequest takes the raw URL param value (as a string) and returns a UUID
+// from it. If param is not a valid UUID, it returns an error.
+func (x UUID) FromRequest(param string) (any, error) {
+
+	invalid := BadRequest(fmt.Sprintf("'%s' isn't a valid UUID", param))
+
+	if len(param) != 36 || param[8] != '-' || param[13] != '-' || param[18] != '-' || param[23] != '-' {
+		return nil, invalid
+	}
+
+	// Any other dashes would leave the hex too short to fill the UUID.
+	digits := strings.ReplaceAll(param, "-", "")
+	if len(digits) != 32 {
+		return nil, invalid
+	}
+
+	var result UUID
+	if _, err := hex.Decode(result[:], []byte(digits)); err != nil {
+		return nil, invalid
+	}
+
+	return result, nil
+
+}
+
+// ToRequest returns the UUID as it would appear in a url param.
+func (x UUID) ToRequest() string {
+	return x.String()
+}
+
+// String returns the UUID in the usual lower case 8-4-4-4-12 format.
+func (x UUID) String() string {
+	h := hex.EncodeToString(x[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// Timestamp is a FromRequestable time, in RFC3339 format
+// (e.g. 2006-01-02T15:04:05Z or 2006-01-02T15:04:05+07:00). Routes using it
+// need WideParams, since timestamps contain : (and maybe . or +).
+type Timestamp struct {
+	time.Time
+}
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// Timestamp from it. If param is not a valid RFC3339 time, it returns an error.
+func (x Timestamp) FromRequest(param string) (any, error) {
+	t, err := time.Parse(time.RFC3339, param)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a valid RFC3339 timestamp", param))
+	}
+
+	return Timestamp{t}, nil
+}
+
+// ToRequest returns the Timestamp as it would appear in a url param. Any
+// fraction of a second is kept, so it survives the trip.
+func (x Timestamp) ToRequest() string {
+	return x.Format(time.RFC3339Nano)
+}
+
+// Date is a FromRequestable date with no time, in YYYY-MM-DD format
+// (e.g. 2006-01-02). The resulting time is midnight UTC on that date.
+type Date struct {
+	time.Time
+}
+
+// dateFormat is the layout used by Date.
+const dateFormat = "2006-01-02"
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// Date from it. If param is not a valid date, it returns an error.
+func (x Date) FromRequest(param string) (any, error) {
+	t, err := time.Parse(dateFormat, param)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a valid YYYY-MM-DD date", param))
+	}
+
+	return Date{t}, nil
+}
+
+// ToRequest returns the Date as it would appear in a url param.
+func (x Date) ToRequest() string {
+	return x.Format(dateFormat)
+}
+
+// Duration is a FromRequestable time.Duration, in the format accepted by
+// time.ParseDuration (e.g. 90s, 1h30m, 1.5h). Fractions like 1.5h only match
+// on routes with WideParams.
+type Duration time.Duration
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// Duration from it. If param is not a valid duration, it returns an error.
+func (x Duration) FromRequest(param string) (any, error) {
+	d, err := time.ParseDuration(param)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a valid duration (try something like 1h30m)", param))
+	}
+
+	return Duration(d), nil
+}
+
+// ToRequest returns the Duration as it would appear in a url param. That's
+// what time.Duration's String method returns, except microseconds are "us"
+// rather than "µs", which isn't allowed in a path.
+func (x Duration) ToRequest() string {
+	return strings.Replace(time.Duration(x).String(), "µs", "us", 1)
+}
+
+// Enum is a FromRequestable string that can only be one of a list of
+// allowed values. Set the allowed values on the type you put in your
+// RouteParams:
+//
+//	orbit.RouteParams{
+//		"colour": orbit.Enum{Allowed: []string{"red", "green", "blue"}},
+//	}
+//
+// Your handler will receive an Enum with Value set to the value from the
+// request.
+type Enum struct {
+	Allowed []string // The values that are allowed.
+	Value   string   // The value from the request. Always one of Allowed.
+}
+
+// FromRequest takes the raw URL param value (as a string) and returns an Enum
+// from it. If param is not one of the allowed values, it returns an error.
+func (x Enum) FromRequest(param string) (any, error) {
+	if !contains(x.Allowed, param) {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't allowed (must be one of: %s)", param, strings.Join(x.Allowed, ", ")))
+	}
+
+	return Enum{Allowed: x.Allowed, Value: param}, nil
+}
+
+// ToRequest returns the Enum's value as it would appear in a url param.
+func (x Enum) ToRequest() string {
+	return x.Value
+}
+
+// BoundedInt is a FromRequestable int that has to be between a minimum and a
+// maximum (inclusive). Set the bounds on the type you put in your RouteParams:
+//
+//	orbit.RouteParams{
+//		"page": orbit.BoundedInt{Min: 1, Max: 100},
+//	}
+//
+// Your handler will receive a BoundedInt with Value set to the value from the
+// request.
+type BoundedInt struct {
+	Min   int // The smallest allowed value.
+	Max   int // The largest allowed value.
+	Value int // The value from the request. Always between Min and Max.
+}
+
+// FromRequest takes the raw URL param value (as a string) and returns a
+// BoundedInt from it. If param is not a valid int, or is out of bounds, it
+// returns an error.
+func (x BoundedInt) FromRequest(param string) (any, error) {
+	intval, err := strconv.Atoi(param)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't a whole number", param))
+	}
+
+	if intval < x.Min || intval > x.Max {
+		return nil, BadRequest(fmt.Sprintf("%d is out of range (must be between %d and %d)", intval, x.Min, x.Max))
+	}
+
+	return BoundedInt{Min: x.Min, Max: x.Max, Value: intval}, nil
+}
+
+// ToRequest returns the BoundedInt's value as it would appear in a url param.
+func (x BoundedInt) ToRequest() string {
+	return strconv.Itoa(x.Value)
+}
+
+// Base64Bytes is a FromRequestable []byte, encoded as url-safe base64 with no
+// padding (see base64.RawURLEncoding) so that it's safe to put in a path.
+type Base64Bytes []byte
+
+// FromRequest takes the raw URL param value (as a string) and returns the
+// Base64Bytes it decodes to. If param is not valid base64, it returns an error.
+func (x Base64Bytes) FromRequest(param string) (any, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(param)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't valid url-safe base64", param))
+	}
+
+	return Base64Bytes(decoded), nil
+}
+
+// ToRequest returns the Base64Bytes as it would appear in a url param.
+func (x Base64Bytes) ToRequest() string {
+	return base64.RawURLEncoding.EncodeToString(x)
+}
+
+// HexBytes is a FromRequestable []byte, encoded as hex (e.g. a SHA or some
+// other hash). Upper and lower case are both accepted.
+type HexBytes []byte
+
+// FromRequest takes the raw URL param value (as a string) and returns the
+// HexBytes it decodes to. If param is not valid hex, it returns an error.
+func (x HexBytes) FromRequest(param string) (any, error) {
+	decoded, err := hex.DecodeString(param)
+	if err != nil {
+		return nil, BadRequest(fmt.Sprintf("'%s' isn't valid hex", param))
+	}
+
+	return HexBytes(decoded), nil
+}
+
+// ToRequest returns the HexBytes as it would appear in a url param.
+func (x HexBytes) ToRequest() string {
+	return hex.EncodeToString(x)
+}
@@ -2,6 +2,7 @@ package orbit
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -38,3 +39,116 @@ func Test_BasicInt_FromRequest_Invalid(t *testing.T) {
 	assert.Error(t, err)
 
 }
+
+func Test_BasicString_ToRequest(t *testing.T) {
+
+	assert.Equal(t, "example_string", BasicString("example_string").ToRequest())
+
+}
+
+func Test_BasicInt_ToRequest(t *testing.T) {
+
+	assert.Equal(t, "-12345", BasicInt(-12345).ToRequest())
+
+}
+
+func Test_HelperTypes_FromRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		proto   FromRequestable
+		param   string
+		want    any
+		wantErr bool
+	}{
+		{name: "int64_valid", proto: BasicInt64(0), param: "-9000000000", want: BasicInt64(-9000000000)},
+		{name: "int64_invalid", proto: BasicInt64(0), param: "1.5", wantErr: true},
+		{name: "uint_valid", proto: BasicUint(0), param: "42", want: BasicUint(42)},
+		{name: "uint_negative", proto: BasicUint(0), param: "-1", wantErr: true},
+		{name: "float_valid", proto: BasicFloat(0), param: "1.5", want: BasicFloat(1.5)},
+		{name: "float_invalid", proto: BasicFloat(0), param: "abc", wantErr: true},
+		{name: "float_nan", proto: BasicFloat(0), param: "NaN", wantErr: true},
+		{name: "bool_true", proto: BasicBool(false), param: "true", want: BasicBool(true)},
+		{name: "bool_zero", proto: BasicBool(false), param: "0", want: BasicBool(false)},
+		{name: "bool_invalid", proto: BasicBool(false), param: "yes", wantErr: true},
+		{
+			name:  "uuid_valid",
+			proto: UUID{},
+			param: "123E4567-e89b-12d3-a456-426614174000",
+			want:  UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+		},
+		{name: "uuid_no_dashes", proto: UUID{}, param: "123e4567e89b12d3a456426614174000", wantErr: true},
+		{name: "uuid_not_hex", proto: UUID{}, param: "123e4567-e89b-12d3-a456-42661417400z", wantErr: true},
+		{name: "uuid_extra_dashes", proto: UUID{}, param: "12345--8-1234-1234-1234-123456789abc", wantErr: true},
+		{
+			name:  "timestamp_valid",
+			proto: Timestamp{},
+			param: "2023-01-02T15:04:05Z",
+			want:  Timestamp{time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)},
+		},
+		{name: "timestamp_date_only", proto: Timestamp{}, param: "2023-01-02", wantErr: true},
+		{name: "date_valid", proto: Date{}, param: "2023-01-02", want: Date{time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		{name: "date_invalid", proto: Date{}, param: "2023-13-02", wantErr: true},
+		{name: "duration_valid", proto: Duration(0), param: "1h30m", want: Duration(90 * time.Minute)},
+		{name: "duration_invalid", proto: Duration(0), param: "soon", wantErr: true},
+		{
+			name:  "enum_valid",
+			proto: Enum{Allowed: []string{"red", "green"}},
+			param: "green",
+			want:  Enum{Allowed: []string{"red", "green"}, Value: "green"},
+		},
+		{name: "enum_invalid", proto: Enum{Allowed: []string{"red", "green"}}, param: "blue", wantErr: true},
+		{name: "bounded_valid", proto: BoundedInt{Min: 1, Max: 10}, param: "10", want: BoundedInt{Min: 1, Max: 10, Value: 10}},
+		{name: "bounded_too_big", proto: BoundedInt{Min: 1, Max: 10}, param: "11", wantErr: true},
+		{name: "bounded_too_small", proto: BoundedInt{Min: 1, Max: 10}, param: "0", wantErr: true},
+		{name: "bounded_not_int", proto: BoundedInt{Min: 1, Max: 10}, param: "five", wantErr: true},
+		{name: "base64_valid", proto: Base64Bytes{}, param: "aGVsbG8_", want: Base64Bytes("hello?")},
+		{name: "base64_invalid", proto: Base64Bytes{}, param: "aGVsbG8/", wantErr: true},
+		{name: "hex_valid", proto: HexBytes{}, param: "DEADbeef", want: HexBytes{0xde, 0xad, 0xbe, 0xef}},
+		{name: "hex_invalid", proto: HexBytes{}, param: "xyz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.proto.FromRequest(tt.param)
+			if tt.wantErr {
+				// Errors should be client-facing 400s
+				assert.Error(t, err)
+				assert.Equal(t, 400, statusFor(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_HelperTypes_RoundTrip(t *testing.T) {
+
+	err := CheckRoundTrip(
+		BasicInt64(-9000000000),
+		BasicUint(42),
+		BasicFloat(1.5),
+		BasicFloat(-0.001),
+		BasicBool(true),
+		UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+		Timestamp{time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)},
+		Timestamp{time.Date(2023, 1, 2, 15, 4, 5, 123456789, time.UTC)},
+		Date{time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Duration(90*time.Minute),
+		Duration(1500*time.Microsecond),
+		Duration(250*time.Microsecond),
+		Enum{Allowed: []string{"red", "green"}, Value: "red"},
+		BoundedInt{Min: -5, Max: 5, Value: -5},
+		Base64Bytes("hello?"),
+		HexBytes{0xde, 0xad, 0xbe, 0xef},
+	)
+
+	assert.NoError(t, err)
+
+}
+
+func Test_UUID_String(t *testing.T) {
+
+	id := UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", id.String())
+
+}
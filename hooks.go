@@ -0,0 +1,168 @@
+package orbit
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// A Hook is a plugin that gets told about things happening in the router, for
+// things like metrics, tracing and auditing. Register hooks with Router.AddHook.
+//
+// Embed NopHook in your hook so you only have to write the methods you care about.
+//
+// For each request, hooks are called in the order they were added:
+//   - OnRequest before the request's matched against any routes
+//   - OnMatch once a route's matched, before its params and body are resolved
+//   - OnError if the request couldn't be handled (including panics)
+//   - OnResponse once the request's been responded to, even if it failed or
+//     nothing matched.
+type Hook interface {
+	// OnBake is called once when the router's baked, after its routes have
+	// been. It can inspect the routes, or wrap their handlers. If it returns
+	// an error, baking fails.
+	OnBake(RouteTable) error
+
+	// OnRequest is called before a request is matched. It returns the request
+	// to carry on handling, so hooks can add to its context (e.g. a tracing
+	// span). Return r if you don't want to change anything.
+	OnRequest(r *http.Request) *http.Request
+
+	// OnMatch is called when a route matches a request.
+	OnMatch(r *http.Request, route RouteInfo)
+
+	// OnError is called when a request couldn't be handled, with the error
+	// that stopped it.
+	OnError(r *http.Request, err error)
+
+	// OnResponse is called when a request's been handled, with the status it
+	// got and how long handling it took. route is the zero RouteInfo if no
+	// route matched.
+	OnResponse(r *http.Request, route RouteInfo, status int, duration time.Duration)
+}
+
+// NopHook does nothing for every event. Embed it in your own Hooks so you only
+// have to implement the methods you need.
+type NopHook struct{}
+
+func (NopHook) OnBake(RouteTable) error                                 { return nil }
+func (NopHook) OnRequest(r *http.Request) *http.Request                 { return r }
+func (NopHook) OnMatch(*http.Request, RouteInfo)                        {}
+func (NopHook) OnError(*http.Request, error)                            {}
+func (NopHook) OnResponse(*http.Request, RouteInfo, int, time.Duration) {}
+
+// RouteInfo describes a route added with Handle.
+type RouteInfo struct {
+	Path    string       // The route's path template, e.g. /user/{user}
+	Name    string       // The route's name (see Named), if it has one.
+	Methods []string     // The methods the route matches, or nil for all of them.
+	Params  []string     // The names of the route's params, in the order they appear in the path.
+	Body    FromBodyable // The route's body type, or nil if it doesn't decode one.
+
+	// How many requests the route was handling when the RouteInfo was made.
+	InFlight int
+}
+
+// info describes the route.
+func (r *route) info() RouteInfo {
+
+	// Once baked, the route knows the order its params appear in. Before then
+	// fall back to sorting them, so the order's at least stable.
+	params := r.orderedParamNames
+	if params == nil {
+		for name := range r.params {
+			params = append(params, name)
+		}
+		sort.Strings(params)
+	}
+
+	info := RouteInfo{
+		Path:    r.path,
+		Name:    r.name,
+		Methods: append([]string(nil), r.methods...),
+		Params:  append([]string(nil), params...),
+		Body:    r.bodyType,
+	}
+
+	if r.inFlight != nil {
+		info.InFlight = int(r.inFlight.Load())
+	}
+
+	return info
+
+}
+
+// A RouteTable is the router's routes, as passed to Hook.OnBake.
+type RouteTable struct {
+	routes []route
+}
+
+// Routes describes each of the routes, in the order they were added.
+func (t RouteTable) Routes() []RouteInfo {
+	infos := make([]RouteInfo, len(t.routes))
+	for i := range t.routes {
+		infos[i] = t.routes[i].info()
+	}
+	return infos
+}
+
+// WrapHandlers replaces every route's handler with the result of wrap, e.g. to
+// time or audit every handler.
+//
+// If the handler you're wrapping might be an ErrorReturningHandler, your
+// wrapper should be one too, or errors it returns won't reach the router.
+func (t RouteTable) WrapHandlers(wrap func(RouteInfo, Handler) Handler) {
+	for i := range t.routes {
+		t.routes[i].handler = wrap(t.routes[i].info(), t.routes[i].handler)
+	}
+}
+
+// AddHook registers hook with the router (see Hook).
+//
+// Like Handle, AddHook panics if it's called after the router has been baked.
+func (router *Router) AddHook(hook Hook) {
+
+	if router.lifecycle == nil {
+		router.lifecycle = &lifecycle{}
+	}
+
+	if router.lifecycle.baked.Load() {
+		panic(errMisconfigured(fmt.Sprintf("can't add hook %T after the router has been baked", hook)))
+	}
+
+	router.hooks = append(router.hooks, hook)
+
+}
+
+// Routes describes each of the router's routes, in the order they were added.
+func (router Router) Routes() []RouteInfo {
+	return RouteTable{routes: router.routes}.Routes()
+}
+
+// finishRequest is called once a request's been handled, to write the access
+// log and tell hooks about the response.
+func (router Router) finishRequest(w *statusRecorder, r *http.Request, start time.Time) {
+
+	duration := time.Since(start)
+
+	status := w.status
+	if status == 0 {
+		// Nothing was written, so net/http will send a 200.
+		status = http.StatusOK
+	}
+
+	var info RouteInfo
+	if w.route != nil {
+		info = w.route.info()
+	}
+
+	if router.AccessLog && router.Logger != nil {
+		router.logAccess(r, info.Path, status, duration)
+	}
+
+	for _, hook := range router.hooks {
+		hook.OnResponse(r, info, status, duration)
+	}
+
+}
@@ -0,0 +1,195 @@
+package orbit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A hook that records everything it's told about.
+type testHookRecorder struct {
+	NopHook
+	mu     sync.Mutex
+	events []string
+	errs   []error
+	status int
+	route  RouteInfo
+}
+
+type testHookCtxKey struct{}
+
+func (h *testHookRecorder) record(event string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *testHookRecorder) OnBake(table RouteTable) error {
+	h.record("bake")
+	return nil
+}
+
+func (h *testHookRecorder) OnRequest(r *http.Request) *http.Request {
+	h.record("request")
+	return r.WithContext(context.WithValue(r.Context(), testHookCtxKey{}, "from hook"))
+}
+
+func (h *testHookRecorder) OnMatch(r *http.Request, route RouteInfo) {
+	h.record("match " + route.Path)
+}
+
+func (h *testHookRecorder) OnError(r *http.Request, err error) {
+	h.record("error")
+	h.errs = append(h.errs, err)
+}
+
+func (h *testHookRecorder) OnResponse(r *http.Request, route RouteInfo, status int, duration time.Duration) {
+	h.record("response")
+	h.status = status
+	h.route = route
+}
+
+// A hook whose OnBake fails.
+type testHookFailsBake struct {
+	NopHook
+}
+
+func (testHookFailsBake) OnBake(RouteTable) error {
+	return errors.New("nope")
+}
+
+func Test_Router_Hooks(t *testing.T) {
+
+	// setup
+	hook := &testHookRecorder{}
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		hook.record("handler")
+		assert.Equal(t, "from hook", r.Context().Value(testHookCtxKey{}))
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	r := NewRouter()
+	r.AddHook(hook)
+	r.Handle("/a/{foo}", handler, []string{"GET"}, RouteParams{"foo": BasicString("")}, nil, Named("a"))
+	assert.NoError(t, r.Bake())
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/b", nil))
+
+	// check
+	assert.Equal(t, []string{"bake", "request", "match /a/{foo}", "handler", "response"}, hook.events)
+	assert.Equal(t, http.StatusAccepted, hook.status)
+	assert.Equal(t, RouteInfo{Path: "/a/{foo}", Name: "a", Methods: []string{"GET"}, Params: []string{"foo"}}, hook.route)
+
+}
+
+func Test_Router_Hooks_Errors(t *testing.T) {
+
+	// setup
+	hook := &testHookRecorder{}
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		panic("oh no")
+	})
+
+	r := NewRouter()
+	r.AddHook(hook)
+	r.Handle("/int/{foo}", handler, nil, RouteParams{"foo": BasicInt(0)}, nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/int/nope", nil))
+	badParamStatus := hook.status
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/int/1", nil))
+	panicStatus := hook.status
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nothing", nil))
+
+	// check
+	assert.Equal(t, http.StatusBadRequest, badParamStatus)
+	assert.Equal(t, http.StatusInternalServerError, panicStatus)
+	assert.Equal(t, http.StatusNotFound, hook.status)
+	assert.Equal(t, RouteInfo{}, hook.route)
+
+	if assert.Len(t, hook.errs, 2) {
+		assert.ErrorAs(t, hook.errs[0], new(ParamErrors))
+		assert.ErrorAs(t, hook.errs[1], new(errPanic))
+	}
+
+}
+
+func Test_Router_Hooks_WrapHandlers(t *testing.T) {
+
+	// setup
+	var wrapped []string
+	hook := &testHookWraps{wrapped: &wrapped}
+
+	r := NewRouter()
+	r.AddHook(hook)
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, nil, nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check
+	assert.Equal(t, []string{"/a"}, wrapped)
+
+}
+
+// A hook that wraps every handler, recording the paths of those that get called.
+type testHookWraps struct {
+	NopHook
+	wrapped *[]string
+}
+
+func (h *testHookWraps) OnBake(table RouteTable) error {
+	table.WrapHandlers(func(info RouteInfo, next Handler) Handler {
+		return HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+			*h.wrapped = append(*h.wrapped, info.Path)
+			next.ServeHTTP(w, r, params, body)
+		})
+	})
+	return nil
+}
+
+func Test_Router_Hooks_BakeFails(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.AddHook(testHookFailsBake{})
+
+	// do
+	err := r.Bake()
+
+	// check
+	assert.ErrorAs(t, err, new(errMisconfigured))
+
+}
+
+func Test_Router_AddHook_AfterBake(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	assert.NoError(t, r.Bake())
+
+	// do / check
+	assert.Panics(t, func() { r.AddHook(NopHook{}) })
+
+}
+
+func Test_Router_Routes(t *testing.T) {
+
+	// setup
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+	r := NewRouter()
+	r.Handle("/{b}/{a}", handler, nil, RouteParams{"a": BasicString(""), "b": BasicString("")}, testBodyableTypeStruct{})
+
+	// check - before baking params are sorted, after they're in path order
+	assert.Equal(t, []string{"a", "b"}, r.Routes()[0].Params)
+	assert.NoError(t, r.Bake())
+	assert.Equal(t, []RouteInfo{{Path: "/{b}/{a}", Params: []string{"b", "a"}, Body: testBodyableTypeStruct{}}}, r.Routes())
+
+}
@@ -0,0 +1,180 @@
+package orbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// The SyntaxCheckable interface is an optional extra for param types used
+// with Lazy. It checks a raw value looks right (e.g. is a number) without
+// doing the expensive bit of resolving it (e.g. looking it up in a database).
+//
+// Lazy params call CheckSyntax when the request comes in, so malformed values
+// are still rejected before your handler's called.
+type SyntaxCheckable interface {
+	CheckSyntax(string) error
+}
+
+// Lazy is a param that's only resolved if (and when) your handler asks for it,
+// for params that are expensive to resolve and that not every request needs.
+// Make one with LazyParam, or use Lazy[T]{} if T's zero value will do:
+//
+//	orbit.RouteParams{"user": orbit.Lazy[User]{}}
+//
+// Your handler receives a Lazy[T], and calls Get to resolve it:
+//
+//	user, err := params["user"].(orbit.Lazy[User]).Get(r.Context())
+//
+// If T implements SyntaxCheckable, the raw value is checked when the request
+// comes in. Otherwise it isn't checked until Get is called.
+//
+// Resolving it is memoised for the request, so calling Get again (or
+// ResolveParam for the same param as a T) doesn't redo the work, and it's
+// released with the request's other params if it needs to be (see Releasable).
+type Lazy[T FromRequestable] struct {
+	el   T      // The prototype to resolve the param with.
+	name string // The param's name, for getting it from the request's cache.
+	raw  string // The param's raw value.
+
+	// Used to memoise resolving the param when there's no request cache
+	// (e.g. in tests), otherwise the request cache does that.
+	result *lazyResult[T]
+}
+
+// lazyResult is a memoised call to Lazy.Get.
+type lazyResult[T FromRequestable] struct {
+	once  sync.Once
+	value T
+	err   error
+}
+
+// LazyParam makes a Lazy param that resolves params using el.
+func LazyParam[T FromRequestable](el T) Lazy[T] {
+	return Lazy[T]{el: el}
+}
+
+// A lazyParam is a param that needs to know its name to resolve itself later,
+// like Lazy. resolveParam calls fromRequestLazily instead of FromRequest.
+type lazyParam interface {
+	fromRequestLazily(name string, raw string) (any, error)
+}
+
+// FromRequest can't be used on its own, since a Lazy needs to know the name of
+// its param (the router calls it another way).
+func (l Lazy[T]) FromRequest(raw string) (any, error) {
+	return nil, errMisconfigured(fmt.Sprintf("%T can only be resolved by the router", l))
+}
+
+// fromRequestLazily checks raw's syntax if it can, and returns a Lazy ready
+// to resolve it later.
+func (l Lazy[T]) fromRequestLazily(name string, raw string) (any, error) {
+
+	if checker, ok := any(l.el).(SyntaxCheckable); ok {
+		if err := checker.CheckSyntax(raw); err != nil {
+			return nil, syntaxError{err: err}
+		}
+	}
+
+	return Lazy[T]{el: l.el, name: name, raw: raw, result: &lazyResult[T]{}}, nil
+
+}
+
+// ToRequest returns the raw value the param was resolved from, so a Lazy can
+// be used to build URLs.
+func (l Lazy[T]) ToRequest() string {
+	return l.raw
+}
+
+// Get resolves the param, if it hasn't been already for this request. ctx
+// should be the request's context.
+//
+// Errors are the same as if the param wasn't lazy, so if you're using an
+// ErrorReturningHandler you can return them for Orbit to respond to.
+func (l Lazy[T]) Get(ctx context.Context) (T, error) {
+
+	var zero T
+	if l.result == nil {
+		return zero, errMisconfigured(fmt.Sprintf("%T hasn't been resolved by the router", l))
+	}
+
+	// Use the request's cache if there is one, so the result's shared with
+	// anything else that resolves it, and released with the request.
+	if cache := requestCacheFrom(ctx); cache != nil {
+		value, err := cache.resolve(ctx, l.name, l.el)
+		if err != nil {
+			return zero, err
+		}
+		return l.asT(value)
+	}
+
+	l.result.once.Do(func() {
+		var value FromRequestable
+		value, l.result.err = resolveParam(ctx, l.name, l.raw, l.el, nil)
+		if l.result.err == nil {
+			l.result.value, l.result.err = l.asT(value)
+		}
+	})
+
+	return l.result.value, l.result.err
+
+}
+
+// asT checks a resolved value is a T. It won't be if T wraps another type
+// (e.g. Lazy[*CachedParam], which resolves to whatever it caches).
+func (l Lazy[T]) asT(value any) (T, error) {
+
+	result, ok := value.(T)
+	if !ok {
+		var zero T
+		return zero, errMisconfigured(fmt.Sprintf("%s resolved to %T, which isn't a %T", l.name, value, zero))
+	}
+
+	return result, nil
+
+}
+
+// MustGet is Get, but panics if it fails. As long as panic recovery's on (see
+// Router.DisablePanicRecovery), the router recovers and responds to the error
+// as if it had happened before your handler was called, e.g. with a 404 if
+// the thing the param refers to doesn't exist.
+func (l Lazy[T]) MustGet(ctx context.Context) T {
+
+	value, err := l.Get(ctx)
+	if err != nil {
+		panic(lazyPanic{err: err})
+	}
+
+	return value
+
+}
+
+// lazyPanic is what MustGet panics with, so the router can respond to the
+// error inside it.
+type lazyPanic struct {
+	err error
+}
+
+// syntaxError is an error from CheckSyntax, or from a ParamFunc's parser.
+// They're client errors (400) unless they say otherwise.
+type syntaxError struct {
+	err error
+}
+
+func (e syntaxError) Error() string {
+	return e.err.Error()
+}
+
+func (e syntaxError) Unwrap() error {
+	return e.err
+}
+
+func (e syntaxError) StatusCode() int {
+	var sc statusCoder
+	if errors.As(e.err, &sc) && sc.StatusCode() != 0 {
+		return sc.StatusCode()
+	}
+	return http.StatusBadRequest
+}
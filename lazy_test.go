@@ -0,0 +1,205 @@
+package orbit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A param type that counts lookups, and whose syntax is checked separately.
+// Values must be lowercase letters, and "missing" isn't found.
+type testTypeExpensive struct {
+	calls *atomic.Int32
+	value string
+}
+
+func (x testTypeExpensive) FromRequest(param string) (any, error) {
+	x.calls.Add(1)
+	if param == "missing" {
+		return nil, NotFound("no such thing")
+	}
+	return testTypeExpensive{calls: x.calls, value: param}, nil
+}
+
+func (x testTypeExpensive) CheckSyntax(param string) error {
+	for _, c := range param {
+		if c < 'a' || c > 'z' {
+			return errors.New("must be lowercase letters")
+		}
+	}
+	return nil
+}
+
+// newLazyRouter builds a router with a route /{thing} whose param is a lazy
+// testTypeExpensive.
+func newLazyRouter(calls *atomic.Int32, handler Handler) Router {
+	r := NewRouter()
+	r.Handle("/{thing}", handler, nil, RouteParams{"thing": LazyParam(testTypeExpensive{calls: calls})}, nil)
+	return r
+}
+
+func Test_Router_E2E_Lazy_NotUsed(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	handlerWasCalled := false
+	r := newLazyRouter(calls, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+	}))
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+	// check
+	assert.True(t, handlerWasCalled)
+	assert.Equal(t, int32(0), calls.Load())
+
+}
+
+func Test_Router_E2E_Lazy_BadSyntax(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	r := newLazyRouter(calls, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Error("handler shouldn't be called")
+	}))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ABC", nil))
+
+	// check
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, int32(0), calls.Load())
+
+}
+
+func Test_Router_E2E_Lazy_Get(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	r := newLazyRouter(calls, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		lazy := params["thing"].(Lazy[testTypeExpensive])
+
+		first, err := lazy.Get(r.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", first.value)
+
+		// Memoised, and shared with ResolveParam
+		second := lazy.MustGet(r.Context())
+		third, _ := ResolveParam(r.Context(), "thing", testTypeExpensive{calls: calls})
+		assert.Equal(t, first, second)
+		assert.Equal(t, first, third)
+	}))
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+	// check
+	assert.Equal(t, int32(1), calls.Load())
+
+}
+
+func Test_Router_E2E_Lazy_MustGetFails(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	r := newLazyRouter(calls, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		params["thing"].(Lazy[testTypeExpensive]).MustGet(r.Context())
+		t.Error("MustGet should have panicked")
+	}))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	// check - the router responds to the error, not the panic
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+}
+
+func Test_Router_E2E_Lazy_GetFails(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	r := newLazyRouter(calls, ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+		_, err := params["thing"].(Lazy[testTypeExpensive]).Get(r.Context())
+		return err
+	}))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	// check
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+}
+
+func Test_Lazy_NoRequestCache(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	resolved, err := resolveParam(context.Background(), "thing", "abc", LazyParam(testTypeExpensive{calls: calls}), nil)
+	assert.NoError(t, err)
+	lazy := resolved.(Lazy[testTypeExpensive])
+
+	// do
+	first, err1 := lazy.Get(context.Background())
+	second, err2 := lazy.Get(context.Background())
+
+	// check
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, "abc", lazy.ToRequest())
+
+}
+
+func Test_Lazy_Unresolved(t *testing.T) {
+
+	// setup
+	lazy := Lazy[testTypeString]{}
+
+	// do
+	_, fromRequestErr := lazy.FromRequest("abc")
+	_, getErr := lazy.Get(context.Background())
+
+	// check
+	assert.ErrorAs(t, fromRequestErr, new(errMisconfigured))
+	assert.ErrorAs(t, getErr, new(errMisconfigured))
+
+}
+
+func Test_Lazy_WrappedType(t *testing.T) {
+
+	// setup - a Lazy[*CachedParam] resolves to what's cached, not a *CachedParam
+	var routerErr error
+	r := NewRouter()
+	r.Handle("/{thing}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		_, routerErr = params["thing"].(Lazy[*CachedParam]).Get(r.Context())
+	}), nil, RouteParams{"thing": LazyParam(Cache(testTypeString(""), CacheOptions{}))}, nil)
+
+	resolved, err := resolveParam(context.Background(), "thing", "abc", LazyParam(Cache(testTypeString(""), CacheOptions{})), nil)
+	assert.NoError(t, err)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc", nil))
+	_, noCacheErr := resolved.(Lazy[*CachedParam]).Get(context.Background())
+
+	// check
+	assert.ErrorAs(t, routerErr, new(errMisconfigured))
+	assert.ErrorAs(t, noCacheErr, new(errMisconfigured))
+
+}
+
+func Test_syntaxError_StatusCode(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, syntaxError{err: errors.New("bad")}.StatusCode())
+	assert.Equal(t, http.StatusNotFound, syntaxError{err: NotFound("gone")}.StatusCode())
+}
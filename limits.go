@@ -0,0 +1,183 @@
+package orbit
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LimitOptions configures a Limiter.
+type LimitOptions struct {
+	// The most requests that can be handled at once. Must be at least 1.
+	MaxInFlight int
+
+	// How many requests can wait for a slot when MaxInFlight are already
+	// being handled. Zero means requests are turned away straight away.
+	MaxQueued int
+
+	// How long queued requests wait for a slot before being turned away.
+	// Zero means they wait until their context is done.
+	MaxWait time.Duration
+
+	// What to tell turned away clients in the Retry-After header. Zero means
+	// DefaultRetryAfter.
+	RetryAfter time.Duration
+}
+
+// DefaultRetryAfter is the Retry-After sent with 503s from a Limiter, unless
+// it says otherwise.
+const DefaultRetryAfter = time.Second
+
+// A Limiter limits how many requests are handled at once, to protect
+// expensive routes. When it's full, new requests wait in a queue (if it has
+// one) and are otherwise turned away with a 503 Service Unavailable and a
+// Retry-After header.
+//
+// Pass the same Limiter to several routes with Limit to limit them as a group.
+// Use MaxInFlight for a limit on just one route.
+type Limiter struct {
+	opts   LimitOptions
+	seq    uint64        // When the limiter was made, relative to others (see sortLimiters).
+	slots  chan struct{} // Holds a value for each request being handled.
+	queued atomic.Int64  // How many requests are waiting for a slot.
+}
+
+// limiterSeq counts the Limiters that have been made, to give each a seq.
+var limiterSeq atomic.Uint64
+
+// NewLimiter makes a Limiter. It panics if opts.MaxInFlight is less than 1.
+func NewLimiter(opts LimitOptions) *Limiter {
+
+	if opts.MaxInFlight < 1 {
+		panic(errMisconfigured("a Limiter needs a MaxInFlight of at least 1"))
+	}
+
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = DefaultRetryAfter
+	}
+
+	return &Limiter{
+		opts:  opts,
+		seq:   limiterSeq.Add(1),
+		slots: make(chan struct{}, opts.MaxInFlight),
+	}
+
+}
+
+// InFlight is how many requests the limiter's letting through right now.
+func (l *Limiter) InFlight() int {
+	return len(l.slots)
+}
+
+// Queued is how many requests are waiting to be let through right now.
+func (l *Limiter) Queued() int {
+	return int(l.queued.Load())
+}
+
+// acquire waits for a slot, if the queue isn't full. Call release once the
+// request's been handled.
+func (l *Limiter) acquire(ctx context.Context) error {
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.queued.Add(1) > int64(l.opts.MaxQueued) {
+		l.queued.Add(-1)
+		return errOverloaded{retryAfter: l.opts.RetryAfter}
+	}
+	defer l.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if l.opts.MaxWait > 0 {
+		timer := time.NewTimer(l.opts.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return errOverloaded{retryAfter: l.opts.RetryAfter}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+}
+
+// release gives back a slot from acquire.
+func (l *Limiter) release() {
+	<-l.slots
+}
+
+// Limit limits the route with limiter, alongside any other routes it's
+// passed to. A route can have several limiters (e.g. its own, and one for a
+// group of routes), and a request has to get a slot from each of them.
+func Limit(limiter *Limiter) RouteOption {
+	return func(r *route) {
+		r.limiters = append(r.limiters, limiter)
+	}
+}
+
+// MaxInFlight limits the route to handling n requests at once, turning away
+// any more with a 503. Use Limit with a Limiter for more options.
+func MaxInFlight(n int) RouteOption {
+	return Limit(NewLimiter(LimitOptions{MaxInFlight: n}))
+}
+
+// sortLimiters puts limiters in the order they were made. Every route acquires
+// its limiters in that order, so two routes sharing limiters can't each hold
+// one the other's waiting for.
+func sortLimiters(limiters []*Limiter) {
+	sort.Slice(limiters, func(i, j int) bool {
+		return limiters[i].seq < limiters[j].seq
+	})
+}
+
+// acquire gets a slot from each of the route's limiters (in order, see
+// sortLimiters), and counts the request as in flight. Call the func it
+// returns once the request's been handled.
+func (r *route) acquire(ctx context.Context) (func(), error) {
+
+	for i, limiter := range r.limiters {
+		if err := limiter.acquire(ctx); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				r.limiters[j].release()
+			}
+			return nil, err
+		}
+	}
+
+	r.inFlight.Add(1)
+
+	return func() {
+		r.inFlight.Add(-1)
+		for i := len(r.limiters) - 1; i >= 0; i-- {
+			r.limiters[i].release()
+		}
+	}, nil
+
+}
+
+// errOverloaded is the error for a request turned away by a Limiter.
+type errOverloaded struct {
+	retryAfter time.Duration
+}
+
+func (e errOverloaded) Error() string {
+	return "too many requests are being handled"
+}
+
+func (e errOverloaded) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e errOverloaded) setHeaders(header http.Header) {
+	header.Set("Retry-After", strconv.Itoa(ceilSeconds(e.retryAfter)))
+}
@@ -0,0 +1,215 @@
+package orbit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingHandler returns a handler that signals on started when it's called,
+// then waits for gate to close.
+func blockingHandler(started chan<- struct{}, gate <-chan struct{}) Handler {
+	return HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		started <- struct{}{}
+		<-gate
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// serveAsync serves a request to path in another goroutine, sending the
+// response down the returned channel.
+func serveAsync(r Router, path string) <-chan *httptest.ResponseRecorder {
+	result := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		result <- w
+	}()
+	return result
+}
+
+// waitFor polls cond until it's true, failing the test if it takes too long.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_Router_MaxInFlight(t *testing.T) {
+
+	// setup
+	started, gate := make(chan struct{}, 1), make(chan struct{})
+	r := NewRouter()
+	r.Handle("/a", blockingHandler(started, gate), nil, nil, nil, MaxInFlight(1))
+
+	first := serveAsync(r, "/a")
+	<-started
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+	inFlight := r.Routes()[0].InFlight
+
+	close(gate)
+	firstW := <-first
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, 1, inFlight)
+	assert.Equal(t, http.StatusOK, firstW.Code)
+	assert.Equal(t, 0, r.Routes()[0].InFlight)
+
+}
+
+func Test_Router_Limit_Queue(t *testing.T) {
+
+	// setup
+	started, gate := make(chan struct{}, 2), make(chan struct{})
+	limiter := NewLimiter(LimitOptions{MaxInFlight: 1, MaxQueued: 1})
+	r := NewRouter()
+	r.Handle("/a", blockingHandler(started, gate), nil, nil, nil, Limit(limiter))
+
+	first := serveAsync(r, "/a")
+	<-started
+	second := serveAsync(r, "/a")
+	waitFor(t, func() bool { return limiter.Queued() == 1 })
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	close(gate)
+	firstW, secondW := <-first, <-second
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "queue should've been full")
+	assert.Equal(t, http.StatusOK, firstW.Code)
+	assert.Equal(t, http.StatusOK, secondW.Code, "queued request should've been handled")
+	assert.Equal(t, 0, limiter.InFlight())
+	assert.Equal(t, 0, limiter.Queued())
+
+}
+
+func Test_Router_Limit_MaxWait(t *testing.T) {
+
+	// setup
+	started, gate := make(chan struct{}, 1), make(chan struct{})
+	limiter := NewLimiter(LimitOptions{
+		MaxInFlight: 1,
+		MaxQueued:   1,
+		MaxWait:     20 * time.Millisecond,
+		RetryAfter:  2500 * time.Millisecond,
+	})
+	r := NewRouter()
+	r.Handle("/a", blockingHandler(started, gate), nil, nil, nil, Limit(limiter))
+
+	first := serveAsync(r, "/a")
+	<-started
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	close(gate)
+	<-first
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "3", w.Header().Get("Retry-After"), "should round up to whole seconds")
+	assert.Equal(t, 0, limiter.Queued())
+
+}
+
+func Test_Router_Limit_Group(t *testing.T) {
+
+	// setup
+	started, gate := make(chan struct{}, 1), make(chan struct{})
+	group := NewLimiter(LimitOptions{MaxInFlight: 1})
+	r := NewRouter()
+	r.Handle("/a", blockingHandler(started, gate), nil, nil, nil, Limit(group))
+	r.Handle("/b", blockingHandler(started, gate), nil, nil, nil, Limit(group))
+	r.Handle("/c", blockingHandler(started, gate), nil, nil, nil)
+
+	first := serveAsync(r, "/a")
+	<-started
+
+	// do
+	wB := httptest.NewRecorder()
+	r.ServeHTTP(wB, httptest.NewRequest(http.MethodGet, "/b", nil))
+	third := serveAsync(r, "/c")
+	<-started
+
+	close(gate)
+	<-first
+	wC := <-third
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, wB.Code, "group limit should apply to /b")
+	assert.Equal(t, http.StatusOK, wC.Code, "/c isn't in the group")
+
+}
+
+func Test_Router_Limit_HeldUntilTimedOutHandlerFinishes(t *testing.T) {
+
+	// setup
+	started, gate := make(chan struct{}, 1), make(chan struct{})
+	limiter := NewLimiter(LimitOptions{MaxInFlight: 1})
+	r := NewRouter()
+	r.Handle("/a", blockingHandler(started, gate), nil, nil, nil, Limit(limiter), Timeout(10*time.Millisecond))
+
+	first := httptest.NewRecorder()
+	r.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/a", nil))
+	<-started
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	close(gate)
+	waitFor(t, func() bool { return limiter.InFlight() == 0 })
+
+	// check
+	assert.Equal(t, http.StatusGatewayTimeout, first.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "timed out handler should still hold its slot")
+
+}
+
+func Test_NewLimiter_Invalid(t *testing.T) {
+
+	// do, check
+	assert.Panics(t, func() { NewLimiter(LimitOptions{}) })
+
+}
+
+func Test_Route_bake_SortsLimiters(t *testing.T) {
+
+	// setup - two routes sharing limiters, given in opposite orders
+	first := NewLimiter(LimitOptions{MaxInFlight: 1})
+	second := NewLimiter(LimitOptions{MaxInFlight: 1})
+	a := route{path: "/a"}
+	b := route{path: "/b"}
+	Limit(first)(&a)
+	Limit(second)(&a)
+	Limit(second)(&b)
+	Limit(first)(&b)
+
+	// do
+	errA := a.bake()
+	errB := b.bake()
+
+	// check
+	assert.NoError(t, errA)
+	assert.NoError(t, errB)
+	assert.Equal(t, []*Limiter{first, second}, a.limiters)
+	assert.Equal(t, []*Limiter{first, second}, b.limiters, "both routes should acquire in the same order")
+
+}
@@ -0,0 +1,168 @@
+package orbit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// The stages of handling a request, used to say where something went wrong in logs.
+const (
+	stageBake      = "bake"
+	stageLimit     = "limit"
+	stageParams    = "params"
+	stageRateLimit = "ratelimit"
+	stageBody      = "body"
+	stageHandler   = "handler"
+)
+
+// stageError records which route, and which stage of handling a request, an
+// error came from. It's only there for logging: it reads and unwraps as the
+// error it's wrapping, so statuses and responses are unaffected.
+type stageError struct {
+	route string // The template of the route that was handling the request.
+	stage string // One of the stage* constants.
+	err   error
+}
+
+func (e stageError) Error() string {
+	return e.err.Error()
+}
+
+func (e stageError) Unwrap() error {
+	return e.err
+}
+
+// statusRecorder is a http.ResponseWriter that remembers what it responded with,
+// so the access log and hooks can report it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int    // The status written, or 0 if nothing's been written yet.
+	route  *route // The route that matched the request, if any did.
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController get at the real ResponseWriter.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush passes through to the real ResponseWriter, if it can flush.
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+// Hijack passes through to the real ResponseWriter, so things like WebSocket
+// upgrades still work. Once the connection's hijacked the response counts as
+// started, and is logged as 101 Switching Protocols.
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T can't be hijacked: %w", w.ResponseWriter, http.ErrNotSupported)
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+
+}
+
+// logError logs an error that stopped r being handled.
+//
+// Client errors (4xx) are logged at Info, since they're expected and not the
+// server's fault. Anything else (misconfiguration, errors without a status,
+// panics) is logged at Error.
+func (router Router) logError(r *http.Request, err error) {
+
+	if router.Logger == nil {
+		return
+	}
+
+	status := statusFor(err)
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	}
+
+	var staged stageError
+	if errors.As(err, &staged) {
+		attrs = append(attrs, slog.String("route", staged.route), slog.String("stage", staged.stage))
+	}
+
+	var paramErrs ParamErrors
+	var paramErr ParamError
+	if errors.As(err, &paramErrs) && len(paramErrs) == 1 {
+		attrs = append(attrs, slog.String("param", paramErrs[0].Name))
+	} else if errors.As(err, &paramErrs) {
+		names := make([]string, len(paramErrs))
+		for i, p := range paramErrs {
+			names[i] = p.Name
+		}
+		attrs = append(attrs, slog.Any("param", names))
+	} else if errors.As(err, &paramErr) {
+		attrs = append(attrs, slog.String("param", paramErr.Name))
+	}
+
+	attrs = append(attrs, slog.Int("status", status))
+
+	var panicErr errPanic
+	if errors.As(err, &panicErr) {
+		attrs = append(attrs, slog.String("panic", fmt.Sprint(panicErr.value)), slog.String("stack", string(panicErr.stack)))
+		router.Logger.LogAttrs(r.Context(), slog.LevelError, "orbit recovered from a panic", attrs...)
+		return
+	}
+
+	attrs = append(attrs, slog.String("error", err.Error()))
+
+	level := slog.LevelError
+	if status >= 400 && status < 500 {
+		level = slog.LevelInfo
+	}
+	router.Logger.LogAttrs(r.Context(), level, "orbit couldn't handle request", attrs...)
+
+}
+
+// logAccess logs a request once it's been handled, for Router.AccessLog.
+func (router Router) logAccess(r *http.Request, route string, status int, duration time.Duration) {
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	}
+	if route != "" {
+		attrs = append(attrs, slog.String("route", route))
+	}
+	attrs = append(attrs,
+		slog.Int("status", status),
+		slog.Duration("duration", duration),
+	)
+
+	router.Logger.LogAttrs(r.Context(), slog.LevelInfo, "orbit handled request", attrs...)
+
+}
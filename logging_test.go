@@ -0,0 +1,283 @@
+package orbit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestLogger returns a JSON logger that logs everything to the returned buffer.
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})), buf
+}
+
+// decodeLogLines decodes each line of a JSON log.
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var decoded map[string]any
+		assert.NoError(t, json.Unmarshal(line, &decoded))
+		lines = append(lines, decoded)
+	}
+	return lines
+}
+
+func Test_Router_logError_ClientError(t *testing.T) {
+
+	// setup
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.Handle("/a/{foo}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, RouteParams{"foo": BasicInt(0)}, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/nope", nil))
+
+	// check
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, "INFO", lines[0]["level"])
+		assert.Equal(t, "GET", lines[0]["method"])
+		assert.Equal(t, "/a/nope", lines[0]["path"])
+		assert.Equal(t, "/a/{foo}", lines[0]["route"])
+		assert.Equal(t, stageParams, lines[0]["stage"])
+		assert.Equal(t, "foo", lines[0]["param"])
+		assert.Equal(t, float64(http.StatusBadRequest), lines[0]["status"])
+	}
+
+}
+
+func Test_Router_logError_ServerAndClientParamErrors(t *testing.T) {
+
+	// setup - x fails without a status (like a database error), n is a bad number
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.Handle("/a/{x}/{n}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, RouteParams{"x": testTypeInt(0), "n": BasicInt(0)}, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/nope/nope", nil))
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, "ERROR", lines[0]["level"])
+		assert.Equal(t, float64(http.StatusServiceUnavailable), lines[0]["status"])
+	}
+
+}
+
+func Test_Router_logError_SeveralParams(t *testing.T) {
+
+	// setup
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.Handle("/{a}/{b}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, RouteParams{"a": BasicInt(0), "b": BasicInt(0)}, nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x/y", nil))
+
+	// check
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, []any{"a", "b"}, lines[0]["param"])
+	}
+
+}
+
+func Test_Router_logError_Misconfiguration(t *testing.T) {
+
+	// setup
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.Handle("/a", ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+		return errors.New("broken")
+	}), nil, nil, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, "ERROR", lines[0]["level"])
+		assert.Equal(t, stageHandler, lines[0]["stage"])
+		assert.Equal(t, "broken", lines[0]["error"])
+		assert.Equal(t, float64(w.Code), lines[0]["status"])
+	}
+
+}
+
+func Test_Router_logError_BakeFails(t *testing.T) {
+
+	// setup
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.Handle("/{a}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, nil, nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+
+	// check
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, "ERROR", lines[0]["level"])
+		assert.Equal(t, stageBake, lines[0]["stage"])
+	}
+
+}
+
+func Test_Router_AccessLog(t *testing.T) {
+
+	// setup
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.AccessLog = true
+	r.Handle("/a/{foo}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		w.WriteHeader(http.StatusTeapot)
+	}), nil, RouteParams{"foo": BasicString("")}, nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/b", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nothing", nil))
+
+	// check
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "INFO", lines[0]["level"])
+		assert.Equal(t, "/a/{foo}", lines[0]["route"])
+		assert.Equal(t, float64(http.StatusTeapot), lines[0]["status"])
+		assert.Contains(t, lines[0], "duration")
+
+		assert.NotContains(t, lines[1], "route")
+		assert.Equal(t, float64(http.StatusNotFound), lines[1]["status"])
+	}
+
+}
+
+func Test_Router_AccessLog_Panic(t *testing.T) {
+
+	// setup
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.AccessLog = true
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		panic("oh no")
+	}), nil, nil, nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check - the panic, then the access log with the 500
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "ERROR", lines[0]["level"])
+		assert.Equal(t, "oh no", lines[0]["panic"])
+		assert.Equal(t, float64(http.StatusInternalServerError), lines[1]["status"])
+	}
+
+}
+
+func Test_statusRecorder(t *testing.T) {
+
+	// setup
+	inner := httptest.NewRecorder()
+	w := &statusRecorder{ResponseWriter: inner}
+
+	// do
+	w.Write([]byte("hi"))
+	w.WriteHeader(http.StatusTeapot)
+	w.Flush()
+
+	// check - the first write wins, like net/http
+	assert.Equal(t, http.StatusOK, w.status)
+	assert.Equal(t, inner, w.Unwrap())
+	assert.True(t, inner.Flushed)
+
+}
+
+// A ResponseWriter that can be hijacked, like a real server's.
+type testHijackableWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (w *testHijackableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func Test_Router_AccessLog_Hijack(t *testing.T) {
+
+	// setup
+	logger, buf := newTestLogger()
+	r := NewRouter()
+	r.Logger = logger
+	r.AccessLog = true
+	r.Handle("/ws", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		hijacker, ok := w.(http.Hijacker)
+		if assert.True(t, ok, "ResponseWriter should be a http.Hijacker") {
+			_, _, err := hijacker.Hijack()
+			assert.NoError(t, err)
+		}
+	}), nil, nil, nil)
+
+	// do
+	w := &testHijackableWriter{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	// check
+	assert.True(t, w.hijacked)
+	lines := decodeLogLines(t, buf)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, float64(http.StatusSwitchingProtocols), lines[0]["status"])
+	}
+
+}
+
+func Test_statusRecorder_Hijack_NotSupported(t *testing.T) {
+
+	// setup
+	w := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+
+	// do
+	_, _, err := w.Hijack()
+
+	// check
+	assert.ErrorIs(t, err, http.ErrNotSupported)
+	assert.Equal(t, 0, w.status)
+
+}
+
+func Test_stageError(t *testing.T) {
+
+	// setup
+	err := stageError{route: "/a", stage: stageBody, err: NotFound("gone")}
+
+	// check - it's transparent
+	assert.Equal(t, "gone", err.Error())
+	assert.Equal(t, http.StatusNotFound, statusFor(err))
+
+}
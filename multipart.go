@@ -0,0 +1,312 @@
+package orbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+)
+
+// Default limits for MultipartForm, used when its limits are left as zero.
+const (
+	DefaultMaxFileSize  = 10 << 20 // 10 MiB per file.
+	DefaultMaxTotalSize = 32 << 20 // 32 MiB for the whole body.
+)
+
+// MultipartForm is a built-in body type for multipart/form-data requests,
+// like HTML forms with file uploads.
+//
+// Unlike most body types, it streams the body rather than reading it all into
+// memory. Each uploaded file is written to a temp file (or passed to OnFile,
+// if it's set) as it arrives. Temp files are deleted once your handler returns.
+//
+// Configure it on the value you pass to Handle:
+//
+//	r.Handle("/upload", handler, []string{"POST"}, nil, orbit.MultipartForm{
+//		MaxFileSize: 5 << 20,
+//	})
+//
+// Your handler receives a MultipartForm with Fields and Files filled in:
+//
+//	form := body.(orbit.MultipartForm)
+//	for _, file := range form.Files["avatar"] {
+//		f, _ := file.Open()
+//		...
+//	}
+//
+// Requests that aren't multipart/form-data get a 415, files (or bodies) that
+// are too big get a 413, and malformed bodies get a 400. Failing to save a
+// file (or an error from OnFile, unless it has a status of its own) is a 500.
+type MultipartForm struct {
+	MaxFileSize  int64  // The largest a single file can be, in bytes. Zero means DefaultMaxFileSize.
+	MaxTotalSize int64  // The largest the whole body can be, in bytes. Zero means DefaultMaxTotalSize.
+	TempDir      string // Where to put temp files. Empty means os.TempDir().
+
+	// If OnFile is set, each file is streamed to it instead of to a temp file,
+	// and won't appear in Files. content is limited to MaxFileSize, and returns
+	// an error if the file is bigger than that.
+	OnFile func(ctx context.Context, field string, filename string, content io.Reader) error
+
+	Fields map[string][]string       // The form's non-file fields, by name.
+	Files  map[string][]UploadedFile // The form's files, by field name.
+}
+
+// An UploadedFile is a file from a MultipartForm, saved to a temp file.
+type UploadedFile struct {
+	Filename string               // The file's name, as given by the client. Don't trust it.
+	Header   textproto.MIMEHeader // The file part's headers (e.g. its Content-Type).
+	Size     int64                // The file's size, in bytes.
+	Path     string               // Where the temp file is. It's deleted once the handler returns.
+}
+
+// Open opens the uploaded file for reading.
+func (f UploadedFile) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// FromBody is here so MultipartForm can be used as a body type. Orbit calls
+// FromBodyRequest instead, since decoding a multipart body needs its headers.
+func (f MultipartForm) FromBody(body io.ReadCloser) (any, error) {
+	return nil, errMisconfigured("MultipartForm needs the whole request, so use FromBodyRequest")
+}
+
+// FromBodyRequest streams the request's multipart body into a MultipartForm.
+func (f MultipartForm) FromBodyRequest(req *http.Request) (any, error) {
+
+	maxFile := f.MaxFileSize
+	if maxFile <= 0 {
+		maxFile = DefaultMaxFileSize
+	}
+	maxTotal := f.MaxTotalSize
+	if maxTotal <= 0 {
+		maxTotal = DefaultMaxTotalSize
+	}
+
+	// Limit the whole body, on a copy of the request so the original's
+	// body is left alone.
+	limited := *req
+	limited.Body = io.NopCloser(&limitedReader{
+		r:         req.Body,
+		remaining: maxTotal,
+		err:       Error{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("request body is too large (max %d bytes)", maxTotal)},
+	})
+
+	mr, err := limited.MultipartReader()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, Error{Status: http.StatusUnsupportedMediaType, Message: "request body must be multipart/form-data"}
+		}
+		return nil, multipartError(err)
+	}
+
+	result := f
+	result.Fields = make(map[string][]string)
+	result.Files = make(map[string][]UploadedFile)
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.RemoveAll()
+			return nil, multipartError(err)
+		}
+
+		if err := result.readPart(req.Context(), part.FormName(), part.FileName(), part.Header, part, maxFile); err != nil {
+			part.Close()
+			result.RemoveAll()
+			return nil, multipartError(err)
+		}
+		part.Close()
+	}
+
+	return result, nil
+
+}
+
+// readPart reads a single part of the form into f.
+func (f *MultipartForm) readPart(ctx context.Context, field string, filename string, header textproto.MIMEHeader, content io.Reader, maxFile int64) error {
+
+	// Parts without names can't be looked up, so skip them.
+	if field == "" {
+		return nil
+	}
+
+	// Parts without filenames are plain fields.
+	if filename == "" {
+		value, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		f.Fields[field] = append(f.Fields[field], string(value))
+		return nil
+	}
+
+	limitedContent := &limitedReader{
+		r:         content,
+		remaining: maxFile,
+		err:       Error{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("%s is too large (max %d bytes)", filename, maxFile)},
+	}
+
+	// Keep track of errors reading the part, so they can be told apart from
+	// errors saving it.
+	src := &readErrRecorder{r: limitedContent}
+
+	if f.OnFile != nil {
+		err := f.OnFile(ctx, field, filename, src)
+		var sc statusCoder
+		if err == nil || (src.err != nil && errors.Is(err, src.err)) || (errors.As(err, &sc) && sc.StatusCode() != 0) {
+			return err
+		}
+		return errUploadFailed{err: err}
+	}
+
+	tmp, err := os.CreateTemp(f.TempDir, "orbit-upload-*")
+	if err != nil {
+		return errUploadFailed{err: err}
+	}
+
+	// Record the file straight away, so it's cleaned up even if copying fails.
+	f.Files[field] = append(f.Files[field], UploadedFile{
+		Filename: filename,
+		Header:   header,
+		Path:     tmp.Name(),
+	})
+
+	size, err := io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if src.err != nil && err == src.err {
+			return err
+		}
+		return errUploadFailed{err: err}
+	}
+
+	f.Files[field][len(f.Files[field])-1].Size = size
+	return nil
+
+}
+
+// RemoveAll deletes the form's temp files. Orbit calls it once your handler
+// returns, so you don't need to.
+func (f MultipartForm) RemoveAll() error {
+
+	var firstErr error
+
+	for _, files := range f.Files {
+		for _, file := range files {
+			if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+
+}
+
+// Value returns the first value of the form field called name, or "" if there
+// isn't one.
+func (f MultipartForm) Value(name string) string {
+	if values := f.Fields[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Field resolves the first value of the form field called name as the type of
+// as, in exactly the same way Orbit resolves url params. For example:
+//
+//	qty, err := form.Field("quantity", orbit.BoundedInt{Min: 1, Max: 10})
+//
+// Missing fields return a 400 Error.
+func (f MultipartForm) Field(name string, as FromRequestable) (FromRequestable, error) {
+
+	values := f.Fields[name]
+	if len(values) == 0 {
+		return nil, ParamError{Name: name, Err: BadRequest("field is missing")}
+	}
+
+	return resolveParam(context.Background(), name, values[0], as, nil)
+
+}
+
+// multipartError turns errors from reading a multipart body into client-facing
+// Errors. Errors that already have a status keep it (including errUploadFailed,
+// for errors on our side), and anything else is treated as a malformed body.
+func multipartError(err error) error {
+
+	var sc statusCoder
+	if errors.As(err, &sc) && sc.StatusCode() != 0 {
+		return err
+	}
+
+	return BadRequest(fmt.Sprintf("couldn't read multipart body (%s)", err.Error()))
+
+}
+
+// errUploadFailed is an error saving an uploaded file on our side (like a full
+// disk), or an error from OnFile without a status of its own. It's a 500, so
+// the details (like temp file paths) aren't shown to the client.
+type errUploadFailed struct {
+	err error
+}
+
+func (e errUploadFailed) Error() string {
+	return fmt.Sprintf("couldn't save uploaded file (%s)", e.err.Error())
+}
+
+func (e errUploadFailed) Unwrap() error {
+	return e.err
+}
+
+func (e errUploadFailed) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// A readErrRecorder reads from r, keeping hold of the error if a read fails.
+type readErrRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (rec *readErrRecorder) Read(p []byte) (int, error) {
+	n, err := rec.r.Read(p)
+	if err != nil && err != io.EOF {
+		rec.err = err
+	}
+	return n, err
+}
+
+// A limitedReader reads from r until remaining bytes have been read, and then
+// returns err if there's any more.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+
+	// Read up to one byte more than allowed, so we can tell the difference
+	// between "exactly at the limit" and "over it".
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.remaining = -1
+		return 0, l.err
+	}
+
+	l.remaining -= int64(n)
+	return n, err
+
+}
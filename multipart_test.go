@@ -0,0 +1,285 @@
+package orbit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildMultipartRequest builds a multipart/form-data request with the given
+// fields and files (filename -> content, all in the "upload" field).
+func buildMultipartRequest(t *testing.T, fields map[string]string, files map[string]string) *http.Request {
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	for name, value := range fields {
+		assert.NoError(t, mw.WriteField(name, value))
+	}
+
+	for filename, content := range files {
+		fw, err := mw.CreateFormFile("upload", filename)
+		assert.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+
+}
+
+func Test_MultipartForm_FromBodyRequest_Valid(t *testing.T) {
+
+	req := buildMultipartRequest(t,
+		map[string]string{"title": "hello", "quantity": "3"},
+		map[string]string{"a.txt": "contents of a"},
+	)
+
+	result, err := MultipartForm{TempDir: t.TempDir()}.FromBodyRequest(req)
+	assert.NoError(t, err)
+
+	form := result.(MultipartForm)
+	defer form.RemoveAll()
+
+	assert.Equal(t, "hello", form.Value("title"))
+	assert.Equal(t, "", form.Value("nope"))
+
+	quantity, err := form.Field("quantity", BoundedInt{Min: 1, Max: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, quantity.(BoundedInt).Value)
+
+	_, err = form.Field("quantity", BoundedInt{Min: 5, Max: 10})
+	assert.Error(t, err)
+
+	_, err = form.Field("nope", BasicString(""))
+	assert.Error(t, err)
+	assert.Equal(t, 400, statusFor(err))
+
+	assert.Len(t, form.Files["upload"], 1)
+	file := form.Files["upload"][0]
+	assert.Equal(t, "a.txt", file.Filename)
+	assert.Equal(t, int64(13), file.Size)
+
+	f, err := file.Open()
+	assert.NoError(t, err)
+	contents, _ := io.ReadAll(f)
+	f.Close()
+	assert.Equal(t, "contents of a", string(contents))
+
+	// Removing should delete the temp file
+	assert.NoError(t, form.RemoveAll())
+	_, err = os.Stat(file.Path)
+	assert.True(t, os.IsNotExist(err), "temp file wasn't removed")
+
+}
+
+func Test_MultipartForm_FromBodyRequest_NotMultipart(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err := MultipartForm{}.FromBodyRequest(req)
+
+	assert.Error(t, err)
+	assert.Equal(t, 415, statusFor(err))
+
+}
+
+func Test_MultipartForm_FromBodyRequest_Malformed(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(`not really multipart`))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=xyz")
+
+	_, err := MultipartForm{}.FromBodyRequest(req)
+
+	assert.Error(t, err)
+	assert.Equal(t, 400, statusFor(err))
+
+}
+
+func Test_MultipartForm_FromBodyRequest_FileTooLarge(t *testing.T) {
+
+	dir := t.TempDir()
+	req := buildMultipartRequest(t, nil, map[string]string{"a.txt": "0123456789"})
+
+	_, err := MultipartForm{TempDir: dir, MaxFileSize: 5}.FromBodyRequest(req)
+
+	assert.Error(t, err)
+	assert.Equal(t, 413, statusFor(err))
+
+	// The partly-written temp file should have been cleaned up
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+
+}
+
+func Test_MultipartForm_FromBodyRequest_FileExactlyAtLimit(t *testing.T) {
+
+	req := buildMultipartRequest(t, nil, map[string]string{"a.txt": "01234"})
+
+	result, err := MultipartForm{TempDir: t.TempDir(), MaxFileSize: 5}.FromBodyRequest(req)
+
+	assert.NoError(t, err)
+	result.(MultipartForm).RemoveAll()
+
+}
+
+func Test_MultipartForm_FromBodyRequest_BodyTooLarge(t *testing.T) {
+
+	req := buildMultipartRequest(t, map[string]string{"a": strings.Repeat("x", 100)}, nil)
+
+	_, err := MultipartForm{MaxTotalSize: 50}.FromBodyRequest(req)
+
+	assert.Error(t, err)
+	assert.Equal(t, 413, statusFor(err))
+
+}
+
+func Test_MultipartForm_FromBodyRequest_OnFile(t *testing.T) {
+
+	dir := t.TempDir()
+	req := buildMultipartRequest(t, nil, map[string]string{"a.txt": "streamed"})
+
+	var streamed string
+	result, err := MultipartForm{
+		TempDir: dir,
+		OnFile: func(ctx context.Context, field string, filename string, content io.Reader) error {
+			assert.Equal(t, "upload", field)
+			assert.Equal(t, "a.txt", filename)
+			b, err := io.ReadAll(content)
+			streamed = string(b)
+			return err
+		},
+	}.FromBodyRequest(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "streamed", streamed)
+	assert.Empty(t, result.(MultipartForm).Files)
+
+	// Nothing should have been written to disk
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+
+}
+
+func Test_MultipartForm_FromBodyRequest_SaveFails(t *testing.T) {
+
+	missingDir := filepath.Join(t.TempDir(), "missing")
+	req := buildMultipartRequest(t, nil, map[string]string{"a.txt": "hello"})
+
+	_, err := MultipartForm{TempDir: missingDir}.FromBodyRequest(req)
+
+	assert.Error(t, err)
+	assert.Equal(t, 500, statusFor(err))
+
+	// The client shouldn't see where temp files go
+	w := httptest.NewRecorder()
+	writeErrorResponse(w, err)
+	assert.NotContains(t, w.Body.String(), missingDir)
+
+}
+
+func Test_MultipartForm_FromBodyRequest_OnFileErrors(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		onFile     func(ctx context.Context, field string, filename string, content io.Reader) error
+		wantStatus int
+	}{
+		{
+			name: "plain_error",
+			onFile: func(ctx context.Context, field string, filename string, content io.Reader) error {
+				return errors.New("bucket unavailable")
+			},
+			wantStatus: 500,
+		},
+		{
+			name: "error_with_status",
+			onFile: func(ctx context.Context, field string, filename string, content io.Reader) error {
+				return Error{Status: 415, Message: "images only"}
+			},
+			wantStatus: 415,
+		},
+		{
+			name: "too_large",
+			onFile: func(ctx context.Context, field string, filename string, content io.Reader) error {
+				_, err := io.ReadAll(content)
+				return fmt.Errorf("reading upload: %w", err)
+			},
+			wantStatus: 413,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := buildMultipartRequest(t, nil, map[string]string{"a.txt": "0123456789"})
+
+			_, err := MultipartForm{MaxFileSize: 5, OnFile: tt.onFile}.FromBodyRequest(req)
+
+			assert.Error(t, err)
+			assert.Equal(t, tt.wantStatus, statusFor(err))
+
+		})
+	}
+
+}
+
+func Test_Router_E2E_Multipart(t *testing.T) {
+
+	handlerWasCalled := false
+	var tempPath string
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+
+		form, ok := body.(MultipartForm)
+		assert.True(t, ok, "body wasn't a MultipartForm")
+		assert.Equal(t, "hello", form.Value("title"))
+		assert.Len(t, form.Files["upload"], 1)
+
+		// The temp file should exist while the handler's running
+		tempPath = form.Files["upload"][0].Path
+		_, err := os.Stat(tempPath)
+		assert.NoError(t, err)
+	})
+
+	r := NewRouter()
+	r.Handle("/upload", handler, []string{"POST"}, nil, MultipartForm{TempDir: t.TempDir()})
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, buildMultipartRequest(t, map[string]string{"title": "hello"}, map[string]string{"a.txt": "aaa"}))
+
+	assert.True(t, handlerWasCalled, "looks like handler didn't get called")
+
+	// ...and be gone once it's returned
+	_, err := os.Stat(tempPath)
+	assert.True(t, os.IsNotExist(err), "temp file wasn't removed")
+
+}
+
+func Test_limitedReader(t *testing.T) {
+
+	l := &limitedReader{r: strings.NewReader("0123456789"), remaining: 4, err: BadRequest("too big")}
+
+	b, err := io.ReadAll(l)
+
+	assert.EqualError(t, err, "too big")
+	assert.True(t, len(b) <= 4)
+
+}
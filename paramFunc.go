@@ -0,0 +1,62 @@
+package orbit
+
+import "fmt"
+
+// Param is a FromRequestable wrapper around a value of any type, resolved by
+// a plain parser func. Make one with ParamFunc.
+//
+// Your handler receives a Param[T] with Value set to whatever the parser
+// returned:
+//
+//	id := params["id"].(orbit.Param[uuid.UUID]).Value
+type Param[T any] struct {
+	Value T // The value the parser returned for this request.
+
+	parse func(string) (T, error)
+}
+
+// ParamFunc adapts an existing parser func into something you can use in
+// RouteParams, without having to declare a wrapper type with a FromRequest
+// method. For example:
+//
+//	orbit.RouteParams{
+//		"id":      orbit.ParamFunc(uuid.Parse),
+//		"verbose": orbit.ParamFunc(strconv.ParseBool),
+//		"team":    orbit.ParamFunc(lookupTeamBySlug),
+//	}
+//
+// Errors returned by parse are treated as bad input from the client, and
+// reported as a 400 Bad Request, unless they have a status of their own (e.g.
+// lookupTeamBySlug could return a NotFound, or an Error with a 5xx status if
+// its database is down).
+func ParamFunc[T any](parse func(string) (T, error)) Param[T] {
+	return Param[T]{parse: parse}
+}
+
+// FromRequest calls the parser with the raw URL param value, and returns a
+// Param holding the result.
+func (p Param[T]) FromRequest(param string) (any, error) {
+
+	if p.parse == nil {
+		return nil, errMisconfigured(fmt.Sprintf("%T has no parser func (make it with ParamFunc)", p))
+	}
+
+	value, err := p.parse(param)
+	if err != nil {
+		return nil, syntaxError{err: err}
+	}
+
+	return Param[T]{Value: value, parse: p.parse}, nil
+
+}
+
+// paramValue returns the wrapped value, so paramToString can convert it.
+func (p Param[T]) paramValue() any {
+	return p.Value
+}
+
+// A valueWrapper is a param type that wraps the value it really represents,
+// like Param.
+type valueWrapper interface {
+	paramValue() any
+}
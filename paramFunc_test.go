@@ -0,0 +1,123 @@
+package orbit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParamFunc_FromRequest_Valid(t *testing.T) {
+
+	var paramType = ParamFunc(strconv.ParseBool)
+
+	result, err := paramType.FromRequest("true")
+	assert.NoError(t, err)
+
+	decoded, ok := result.(Param[bool])
+	assert.True(t, ok, "FromRequest returned the wrong type")
+	assert.Equal(t, true, decoded.Value)
+
+}
+
+func Test_ParamFunc_FromRequest_Invalid(t *testing.T) {
+
+	var paramType = ParamFunc(strconv.Atoi)
+
+	_, err := paramType.FromRequest("aaaaa")
+	assert.ErrorIs(t, err, strconv.ErrSyntax)
+	assert.Equal(t, http.StatusBadRequest, statusFor(err))
+
+}
+
+func Test_ParamFunc_FromRequest_NoParser(t *testing.T) {
+
+	var paramType Param[int]
+
+	_, err := paramType.FromRequest("12")
+	assert.Error(t, err)
+
+}
+
+func Test_ParamFunc_newFromRequest(t *testing.T) {
+
+	var paramTypes = RouteParams{
+		"id": ParamFunc(strconv.Atoi),
+	}
+
+	result, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{"id": "12"}), nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 12, (*result)["id"].(Param[int]).Value)
+
+}
+
+func Test_ParamFunc_URL(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+
+	r := NewRouter()
+	r.Handle("/a/{id}", handler, nil, RouteParams{"id": ParamFunc(strconv.Atoi)}, nil, Named("a"))
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	path, err := r.URL("a", RouteParams{"id": Param[int]{Value: 12}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/a/12", path)
+
+}
+
+func Test_ParamFunc_E2E(t *testing.T) {
+
+	handlerWasCalled := false
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+		assert.Equal(t, 12, params["id"].(Param[int]).Value)
+	})
+
+	r := NewRouter()
+	r.Handle("/a/{id}", handler, nil, RouteParams{"id": ParamFunc(strconv.Atoi)}, nil)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/12", nil))
+
+	assert.True(t, handlerWasCalled, "looks like handler didn't get called")
+
+}
+
+func Test_ParamFunc_E2E_ErrorStatus(t *testing.T) {
+
+	testCases := []struct {
+		name   string
+		param  FromRequestable
+		expect int
+	}{
+		{name: "parser error", param: ParamFunc(strconv.Atoi), expect: http.StatusBadRequest},
+		{name: "not found", param: ParamFunc(func(string) (int, error) { return 0, NotFound("no such team") }), expect: http.StatusNotFound},
+		{name: "server error", param: ParamFunc(func(string) (int, error) { return 0, Error{Status: http.StatusInternalServerError, Message: "db down"} }), expect: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// setup
+			r := NewRouter()
+			r.Handle("/a/{id}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+				t.Error("handler shouldn't be called")
+			}), nil, RouteParams{"id": tc.param}, nil)
+
+			// do
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/abc", nil))
+
+			// check
+			assert.Equal(t, tc.expect, w.Code)
+
+		})
+	}
+
+}
@@ -1,8 +1,12 @@
 package orbit
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"sync"
 )
 
 // RouteParams are the values extracted from URL parameters (e.g. /from/{this}/)
@@ -21,29 +25,360 @@ import (
 // with the data it decoded from the url.
 type RouteParams map[string]FromRequestable
 
-// newFromRequest takes a request path (e.g. /a/b/{c}/d) and returns a copy
-// with all arguments populated from that request.
+// A resolvePlan describes how to resolve a route's params: which order to go
+// in, which params have to wait for others, and whether to resolve them one at
+// a time or all at once. Routes build theirs when they're baked.
+type resolvePlan struct {
+	pathOrder []string            // Every param, in the order they appear in the path.
+	order     []string            // Every param, ordered so each comes after its dependencies.
+	deps      map[string][]string // The params each param has to wait for.
+	parallel  bool                // Resolve params concurrently, instead of one at a time.
+}
+
+// newResolvePlan orders params so that each comes after its dependencies,
+// keeping to the order they appear in the path (orderedParamNames) where it
+// can. It returns an error if deps mentions params that don't exist, or if
+// there's a dependency cycle.
+//
+// As well as the dependencies in deps, params whose types implement
+// FromRequestWithParamsable depend on every param before them in the path.
+func newResolvePlan(orderedParamNames []string, params RouteParams, deps map[string][]string, parallel bool) (*resolvePlan, error) {
+
+	// Check every param mentioned in deps is actually in the path.
+	for param, paramDeps := range deps {
+		for _, name := range append([]string{param}, paramDeps...) {
+			if !contains(orderedParamNames, name) {
+				return nil, errMisconfigured(fmt.Sprintf("dependency mentions {%s}, which isn't in the path", name))
+			}
+		}
+	}
+
+	// Add the implicit dependencies, without changing the map we were given.
+	allDeps := make(map[string][]string, len(deps))
+	for param, paramDeps := range deps {
+		allDeps[param] = paramDeps
+	}
+	for idx, name := range orderedParamNames {
+		if _, ok := params[name].(FromRequestWithParamsable); ok {
+			allDeps[name] = append(append([]string{}, allDeps[name]...), orderedParamNames[:idx]...)
+		}
+	}
+	deps = allDeps
+
+	// Repeatedly sweep through the params in path order, picking out any whose
+	// dependencies have all been picked. If a sweep picks nothing, there's a cycle.
+	order := make([]string, 0, len(orderedParamNames))
+	picked := make(map[string]bool, len(orderedParamNames))
+
+	for len(order) < len(orderedParamNames) {
+
+		progressed := false
+
+		for _, name := range orderedParamNames {
+			if picked[name] || !allPicked(deps[name], picked) {
+				continue
+			}
+			order = append(order, name)
+			picked[name] = true
+			progressed = true
+		}
+
+		if !progressed {
+			return nil, errMisconfigured("param dependencies contain a cycle")
+		}
+
+	}
+
+	return &resolvePlan{pathOrder: orderedParamNames, order: order, deps: deps, parallel: parallel}, nil
+
+}
+
+// allPicked checks whether every name in names is in picked.
+func allPicked(names []string, picked map[string]bool) bool {
+	for _, name := range names {
+		if !picked[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// newFromRequest resolves the params from a request, and returns a copy of
+// params with all of them populated. Resolved values are memoised in cache,
+// which holds the request's raw param values.
+//
+// The params are resolved following plan. If plan is nil, they're resolved one
+// at a time in alphabetical order.
 //
 // To be successful, *all* fields must populate correctly. If any fields fail
-// to populate, then an error is returned.
-func (params RouteParams) newFromRequest(tokens map[string]string) (*RouteParams, error) {
+// to populate, then a ParamErrors listing all of them (in path order) is
+// returned. Params that depend on a param that failed aren't resolved, and
+// aren't listed.
+func (params RouteParams) newFromRequest(ctx context.Context, cache *requestCache, plan *resolvePlan) (*RouteParams, error) {
+
+	if plan == nil {
+		names := make([]string, 0, len(params))
+		for key := range params {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+
+		var err error
+		if plan, err = newResolvePlan(names, params, nil, false); err != nil {
+			return nil, err
+		}
+	}
+
+	// Let the cache know about the route, so it can resolve params that
+	// depend on the ones before them.
+	cache.declared = params
+	cache.pathOrder = plan.pathOrder
+
+	// Make sure resolvers can get at the cache (and so each other) through ctx.
+	if requestCacheFrom(ctx) != cache {
+		ctx = withRequestCache(ctx, cache)
+	}
+
+	if plan.parallel {
+		return params.newFromRequestParallel(ctx, cache, plan)
+	}
 
 	filled := make(RouteParams)
-	rvfilled := reflect.ValueOf(filled)
+	failed := make(map[string]error)
+
+	for _, key := range plan.order {
+
+		// Skip params whose dependencies failed. They can't succeed, and the
+		// dependency's error says everything there is to say.
+		if anyFailed(plan.deps[key], failed) {
+			failed[key] = nil
+			continue
+		}
 
-	for key, el := range params {
-		result, err := el.FromRequest(tokens[key])
+		result, err := cache.resolve(ctx, key, params[key])
 		if err != nil {
-			return nil, errCoudlntGetParams{paramName: key, err: err}
+			failed[key] = err
+			continue
+		}
+		filled[key] = result
+	}
+
+	if err := collectParamErrors(plan.pathOrder, failed); err != nil {
+		return nil, err
+	}
+
+	return &filled, nil
+
+}
+
+// anyFailed checks whether any of names are in failed.
+func anyFailed(names []string, failed map[string]error) bool {
+	for _, name := range names {
+		if _, ok := failed[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// collectParamErrors gathers the errors in failed into a ParamErrors, in the
+// order the params appear in the path. nil errors (for params that were
+// skipped) are left out.
+//
+// Errors that aren't ParamErrors mean Orbit is misconfigured rather than the
+// request being bad, so if there are any the first is returned on its own.
+func collectParamErrors(pathOrder []string, failed map[string]error) error {
+
+	var paramErrs ParamErrors
+
+	for _, key := range pathOrder {
+		err := failed[key]
+		if err == nil {
+			continue
 		}
 
-		// use reflection to check the type returned is correct
-		if reflect.TypeOf(result) != reflect.TypeOf(el) {
-			return nil, errMisconfigured(fmt.Sprintf("%s's FromRequest method returned unexpected type (want %s got %s)", key, reflect.TypeOf(el), reflect.TypeOf(result)))
+		paramErr, ok := err.(ParamError)
+		if !ok {
+			return err
 		}
-		rvfilled.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(result))
+		paramErrs = append(paramErrs, paramErr)
+	}
+
+	if len(paramErrs) == 0 {
+		return nil
+	}
+
+	return paramErrs
+
+}
+
+// newFromRequestParallel is newFromRequest, but resolves each param in its own
+// goroutine. Params wait for their dependencies before resolving.
+//
+// The first failure cancels the context passed to the other params' resolvers,
+// and stops any that haven't started yet from starting. Params that fail
+// because of that cancellation aren't included in the returned ParamErrors.
+func (params RouteParams) newFromRequestParallel(ctx context.Context, cache *requestCache, plan *resolvePlan) (*RouteParams, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each param's channel is closed when it's finished resolving (successfully or not).
+	done := make(map[string]chan struct{}, len(plan.order))
+	for _, key := range plan.order {
+		done[key] = make(chan struct{})
+	}
+
+	var (
+		mu        sync.Mutex
+		filled    = make(RouteParams, len(plan.order))
+		failed    = make(map[string]error)
+		cancelled bool // Whether we've cancelled ctx because something failed.
+		wg        sync.WaitGroup
+	)
+
+	for _, key := range plan.order {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer close(done[key])
+
+			// A panic here wouldn't reach the router's recovery, since it's
+			// on another goroutine, so report it as a failure instead.
+			defer func() {
+				if value := recover(); value != nil {
+					mu.Lock()
+					defer mu.Unlock()
+					failed[key] = panicError(value)
+					if !cancelled {
+						cancelled = true
+						cancel()
+					}
+				}
+			}()
+
+			// Wait for dependencies. If anything fails in the meantime the
+			// context is cancelled, so there's no point carrying on.
+			for _, dep := range plan.deps[key] {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			result, err := cache.resolve(ctx, key, params[key])
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				// Failures caused by us cancelling ctx aren't worth reporting.
+				if cancelled && errors.Is(err, context.Canceled) {
+					return
+				}
+				failed[key] = err
+				if !cancelled {
+					cancelled = true
+					cancel()
+				}
+				return
+			}
+			filled[key] = result
+		}(key)
+	}
+
+	wg.Wait()
+
+	if err := collectParamErrors(plan.pathOrder, failed); err != nil {
+		return nil, err
+	}
+
+	// If the request itself was cancelled, some params may not have resolved.
+	if err := ctx.Err(); err != nil && len(filled) != len(plan.order) {
+		return nil, err
 	}
 
 	return &filled, nil
 
 }
+
+// resolveParam resolves a single param from its raw value, by calling el's
+// FromRequest (or one of the alternatives, see callFromRequest), and checks
+// the result is the same type as el and valid (see Validatable).
+//
+// earlier is only used for FromRequestWithParams, and should hold the params
+// before this one in the path.
+func resolveParam(ctx context.Context, key string, raw string, el FromRequestable, earlier RouteParams) (FromRequestable, error) {
+
+	var result any
+	var err error
+	if lazy, ok := el.(lazyParam); ok {
+		result, err = lazy.fromRequestLazily(key, raw)
+	} else {
+		result, err = callFromRequest(ctx, el, raw, earlier)
+	}
+	if err != nil {
+		return nil, ParamError{Name: key, Value: raw, Err: err}
+	}
+
+	// use reflection to check the type returned is correct
+	if reflect.TypeOf(result) != paramType(el) {
+		return nil, errMisconfigured(fmt.Sprintf("%s's FromRequest method returned unexpected type (want %s got %s)", key, paramType(el), reflect.TypeOf(result)))
+	}
+
+	// If the param type knows how to validate itself, check it's valid. If it
+	// isn't, nothing will release it later, so do that now.
+	if err := validate(result); err != nil {
+		err = ParamError{Name: key, Value: raw, Err: err}
+		release(result, err)
+		return nil, err
+	}
+
+	// Since it's the same type as el, it must be FromRequestable too.
+	return result.(FromRequestable), nil
+
+}
+
+// callFromRequest calls whichever of el's FromRequest methods it prefers:
+// FromRequestWithParams, FromRequestBatch, FromRequestWithState,
+// FromRequestContext, then plain FromRequest.
+func callFromRequest(ctx context.Context, el FromRequestable, raw string, earlier RouteParams) (any, error) {
+
+	if withParams, ok := el.(FromRequestWithParamsable); ok {
+		return withParams.FromRequestWithParams(raw, earlier)
+	}
+	if batchable, ok := el.(FromRequestBatchable); ok {
+		return fromRequestBatch(ctx, batchable, raw)
+	}
+	if withState, ok := el.(FromRequestWithStateable); ok {
+		return withState.FromRequestWithState(ctx, StateFrom(ctx), raw)
+	}
+	if withContext, ok := el.(FromRequestContextable); ok {
+		return withContext.FromRequestContext(ctx, raw)
+	}
+	return el.FromRequest(raw)
+
+}
+
+// paramWrapper is implemented by param types that wrap another param type to
+// change how it's resolved (like CachedParam), but resolve to the same type
+// as the one they're wrapping.
+type paramWrapper interface {
+	unwrapParam() FromRequestable
+}
+
+// paramType is the type that el resolves to. That's el's own type, unless el
+// is a wrapper (see paramWrapper), in which case it's the wrapped type's.
+func paramType(el FromRequestable) reflect.Type {
+	for {
+		wrapper, ok := el.(paramWrapper)
+		if !ok {
+			return reflect.TypeOf(el)
+		}
+		el = wrapper.unwrapParam()
+	}
+}
@@ -1,7 +1,11 @@
 package orbit
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -22,11 +26,11 @@ func Test_newFromRequest_Valid(t *testing.T) {
 	}
 
 	// do
-	result, err := paramTypes.newFromRequest(map[string]string{
+	result, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
 		"stringparam": "hello",
 		"intparam":    "12345",
 		"structparam": "world",
-	})
+	}), nil)
 
 	// check
 	assert.NoError(t, err)
@@ -44,11 +48,11 @@ func Test_newFromRequest_Unparsable(t *testing.T) {
 	}
 
 	// do
-	_, err := paramTypes.newFromRequest(map[string]string{
+	_, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
 		"stringparam": "hello",
 		"intparam":    "NOT_AN_INT",
 		"structparam": "world",
-	})
+	}), nil)
 
 	// check
 	assert.Error(t, err)
@@ -65,10 +69,10 @@ func Test_newFromRequest_WrongLength(t *testing.T) {
 	}
 
 	// do
-	_, err := paramTypes.newFromRequest(map[string]string{
+	_, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
 		"stringparam": "hello",
 		"structparam": "world",
-	})
+	}), nil)
 
 	// check
 	assert.Error(t, err)
@@ -85,13 +89,362 @@ func Test_newFromRequest_BadTypes(t *testing.T) {
 	}
 
 	// do
-	_, err := paramTypes.newFromRequest(map[string]string{
+	_, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
 		"stringparam": "hello",
 		"intparam":    "12345",
 		"structparam": "world",
-	})
+	}), nil)
 
 	// check
 	assert.Error(t, err)
 
 }
+
+// Dummy type that resolves with the context. It waits until gate is closed
+// (or the context is cancelled) before resolving, then optionally fails.
+type testTypeGated struct {
+	started chan<- string
+	gate    <-chan struct{}
+	fail    bool
+	value   string
+}
+
+func (x testTypeGated) FromRequest(param string) (any, error) {
+	return nil, errors.New("should have called FromRequestContext")
+}
+
+func (x testTypeGated) FromRequestContext(ctx context.Context, param string) (any, error) {
+	if x.started != nil {
+		x.started <- param
+	}
+	if x.fail {
+		return nil, errors.New("failed on purpose")
+	}
+	if x.gate != nil {
+		select {
+		case <-x.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return testTypeGated{value: param}, nil
+}
+
+// Dummy type that needs {parent} to have resolved first.
+type testTypeChild struct {
+	value string
+}
+
+func (x testTypeChild) FromRequest(param string) (any, error) {
+	return nil, errors.New("should have called FromRequestContext")
+}
+
+func (x testTypeChild) FromRequestContext(ctx context.Context, param string) (any, error) {
+	parent, err := ResolveParam(ctx, "parent", testTypeString(""))
+	if err != nil {
+		return nil, err
+	}
+	return testTypeChild{value: string(parent.(testTypeString)) + "/" + param}, nil
+}
+
+func Test_newResolvePlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		ordered   []string
+		deps      map[string][]string
+		wantOrder []string
+		wantErr   bool
+	}{
+		{name: "no_deps", ordered: []string{"a", "b", "c"}, wantOrder: []string{"a", "b", "c"}},
+		{name: "deps_already_in_order", ordered: []string{"a", "b", "c"}, deps: map[string][]string{"b": {"a"}}, wantOrder: []string{"a", "b", "c"}},
+		{name: "deps_reorder", ordered: []string{"a", "b", "c"}, deps: map[string][]string{"a": {"c"}}, wantOrder: []string{"b", "c", "a"}},
+		{name: "chain", ordered: []string{"a", "b", "c"}, deps: map[string][]string{"a": {"b"}, "b": {"c"}}, wantOrder: []string{"c", "b", "a"}},
+		{name: "unknown_param", ordered: []string{"a", "b"}, deps: map[string][]string{"a": {"z"}}, wantErr: true},
+		{name: "unknown_dependent", ordered: []string{"a", "b"}, deps: map[string][]string{"z": {"a"}}, wantErr: true},
+		{name: "cycle", ordered: []string{"a", "b", "c"}, deps: map[string][]string{"a": {"b"}, "b": {"a"}}, wantErr: true},
+		{name: "self", ordered: []string{"a"}, deps: map[string][]string{"a": {"a"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := newResolvePlan(tt.ordered, nil, tt.deps, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newResolvePlan() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.wantOrder, plan.order)
+			}
+		})
+	}
+}
+
+func Test_newFromRequest_Parallel(t *testing.T) {
+
+	// setup - every param blocks until all three have started, so this only
+	// finishes if they're resolved concurrently.
+	started := make(chan string, 3)
+	gate := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			<-started
+		}
+		close(gate)
+	}()
+
+	var paramTypes = RouteParams{
+		"a": testTypeGated{started: started, gate: gate},
+		"b": testTypeGated{started: started, gate: gate},
+		"c": testTypeGated{started: started, gate: gate},
+	}
+
+	plan, err := newResolvePlan([]string{"a", "b", "c"}, paramTypes, nil, true)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// do
+	result, err := paramTypes.newFromRequest(ctx, newRequestCache(map[string]string{
+		"a": "one",
+		"b": "two",
+		"c": "three",
+	}), plan)
+
+	// check
+	assert.NoError(t, err)
+	assert.Equal(t, RouteParams{
+		"a": testTypeGated{value: "one"},
+		"b": testTypeGated{value: "two"},
+		"c": testTypeGated{value: "three"},
+	}, *result)
+
+}
+
+func Test_newFromRequest_Parallel_FailureCancels(t *testing.T) {
+
+	// setup - "slow" would block forever if the failure didn't cancel it
+	var paramTypes = RouteParams{
+		"slow":   testTypeGated{gate: make(chan struct{})},
+		"broken": testTypeGated{fail: true},
+	}
+
+	plan, err := newResolvePlan([]string{"slow", "broken"}, paramTypes, nil, true)
+	assert.NoError(t, err)
+
+	// do
+	_, err = paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
+		"slow":   "one",
+		"broken": "two",
+	}), plan)
+
+	// check
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "failed on purpose")
+
+}
+
+func Test_newFromRequest_Dependencies(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		t.Run(fmt.Sprintf("parallel_%t", parallel), func(t *testing.T) {
+
+			// setup - child comes first in the path, but depends on parent
+			var paramTypes = RouteParams{
+				"child":  testTypeChild{},
+				"parent": testTypeString(""),
+			}
+
+			plan, err := newResolvePlan([]string{"child", "parent"}, paramTypes, map[string][]string{"child": {"parent"}}, parallel)
+			assert.NoError(t, err)
+
+			// do
+			result, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
+				"child":  "kid",
+				"parent": "mum",
+			}), plan)
+
+			// check
+			assert.NoError(t, err)
+			assert.Equal(t, testTypeChild{value: "mum/kid"}, (*result)["child"])
+
+		})
+	}
+}
+
+func Test_newFromRequest_Dependencies_ParentFails(t *testing.T) {
+
+	// setup - child shouldn't be resolved at all if its parent fails
+	started := make(chan string, 2)
+
+	var paramTypes = RouteParams{
+		"child":  testTypeGated{started: started},
+		"parent": testTypeGated{started: started, fail: true},
+	}
+
+	plan, err := newResolvePlan([]string{"parent", "child"}, paramTypes, map[string][]string{"child": {"parent"}}, true)
+	assert.NoError(t, err)
+
+	// do
+	_, err = paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
+		"child":  "kid",
+		"parent": "mum",
+	}), plan)
+
+	// check
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(started), "child was resolved even though its parent failed")
+
+}
+
+// Dummy type that's scoped by the params before it in the path.
+type testTypeScoped struct {
+	scope string
+	value string
+}
+
+func (x testTypeScoped) FromRequest(param string) (any, error) {
+	return nil, errors.New("should have called FromRequestWithParams")
+}
+
+func (x testTypeScoped) FromRequestWithParams(param string, resolved RouteParams) (any, error) {
+	org, ok := resolved["org"].(testTypeString)
+	if !ok {
+		return nil, errors.New("org wasn't resolved first")
+	}
+	if _, ok := resolved["repo"]; ok {
+		return nil, errors.New("got passed a param after this one")
+	}
+	return testTypeScoped{scope: string(org), value: param}, nil
+}
+
+func Test_newResolvePlan_ImplicitDependencies(t *testing.T) {
+
+	var paramTypes = RouteParams{
+		"org":   testTypeString(""),
+		"repo":  testTypeScoped{},
+		"other": testTypeString(""),
+	}
+
+	plan, err := newResolvePlan([]string{"org", "repo", "other"}, paramTypes, nil, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"org"}, plan.deps["repo"])
+	assert.Empty(t, plan.deps["other"])
+
+}
+
+func Test_newFromRequest_WithParams(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		t.Run(fmt.Sprintf("parallel_%t", parallel), func(t *testing.T) {
+
+			// setup
+			var paramTypes = RouteParams{
+				"org":   testTypeString(""),
+				"repo":  testTypeScoped{},
+				"other": testTypeString(""),
+			}
+
+			plan, err := newResolvePlan([]string{"org", "repo", "other"}, paramTypes, nil, parallel)
+			assert.NoError(t, err)
+
+			// do
+			result, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
+				"org":   "acme",
+				"repo":  "rockets",
+				"other": "thing",
+			}), plan)
+
+			// check
+			assert.NoError(t, err)
+			assert.Equal(t, testTypeScoped{scope: "acme", value: "rockets"}, (*result)["repo"])
+
+		})
+	}
+}
+
+func Test_newFromRequest_WithParams_EarlierFails(t *testing.T) {
+
+	// setup
+	var paramTypes = RouteParams{
+		"org":  testTypeInt(0),
+		"repo": testTypeScoped{},
+	}
+
+	plan, err := newResolvePlan([]string{"org", "repo"}, paramTypes, nil, false)
+	assert.NoError(t, err)
+
+	// do
+	_, err = paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
+		"org":  "NOT_AN_INT",
+		"repo": "rockets",
+	}), plan)
+
+	// check - the error should be from org, not repo
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "couldn't get org")
+
+}
+
+func Test_newFromRequest_AllErrorsInPathOrder(t *testing.T) {
+
+	// setup
+	var paramTypes = RouteParams{
+		"first":  testTypeInt(0),
+		"middle": testTypeString(""),
+		"second": testTypeInt(0),
+		"third":  BasicBool(false),
+	}
+
+	plan, err := newResolvePlan([]string{"third", "first", "middle", "second"}, paramTypes, nil, false)
+	assert.NoError(t, err)
+
+	// Map iteration order is random, so check a few times that the result is
+	// always the same.
+	for i := 0; i < 20; i++ {
+
+		// do
+		_, err := paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
+			"first":  "one",
+			"middle": "fine",
+			"second": "two",
+			"third":  "three",
+		}), plan)
+
+		// check
+		var paramErrs ParamErrors
+		assert.True(t, errors.As(err, &paramErrs), "expected ParamErrors, got %v", err)
+		assert.Len(t, paramErrs, 3)
+		assert.Equal(t, "third", paramErrs[0].Name)
+		assert.Equal(t, "three", paramErrs[0].Value)
+		assert.Equal(t, "first", paramErrs[1].Name)
+		assert.Equal(t, "one", paramErrs[1].Value)
+		assert.Equal(t, "second", paramErrs[2].Name)
+		assert.Equal(t, "two", paramErrs[2].Value)
+
+	}
+
+}
+
+func Test_newFromRequest_DependentsOfFailuresNotReported(t *testing.T) {
+
+	// setup
+	var paramTypes = RouteParams{
+		"org":  testTypeInt(0),
+		"repo": testTypeScoped{},
+	}
+
+	plan, err := newResolvePlan([]string{"org", "repo"}, paramTypes, nil, false)
+	assert.NoError(t, err)
+
+	// do
+	_, err = paramTypes.newFromRequest(context.Background(), newRequestCache(map[string]string{
+		"org":  "NOT_AN_INT",
+		"repo": "rockets",
+	}), plan)
+
+	// check
+	var paramErrs ParamErrors
+	assert.True(t, errors.As(err, &paramErrs), "expected ParamErrors, got %v", err)
+	assert.Len(t, paramErrs, 1)
+	assert.Equal(t, "org", paramErrs[0].Name)
+
+}
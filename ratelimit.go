@@ -0,0 +1,306 @@
+package orbit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A RateLimitKeyFunc works out which bucket a request takes a token from, e.g.
+// the user making it. It's called once the request's params are resolved
+// (unless RateLimitOptions.BeforeParams is set), so it can use them:
+//
+//	func(r *http.Request, params orbit.RouteParams) (string, error) {
+//		return params["user"].(User).ID, nil
+//	}
+//
+// If it returns an empty key, the request isn't limited. If it returns an
+// error, the router responds to it instead of calling the handler.
+type RateLimitKeyFunc func(r *http.Request, params RouteParams) (string, error)
+
+// RateLimitOptions configures a RateLimiter.
+type RateLimitOptions struct {
+	// How many requests each key can make Per period, on average. Requests
+	// must be at least 1 and Per must be positive.
+	Requests int
+	Per      time.Duration
+
+	// How many requests each key can make in a burst. Zero means Requests.
+	Burst int
+
+	// Works out the key a request is limited by. Nil means the client's IP
+	// address (from the request's RemoteAddr).
+	Key RateLimitKeyFunc
+
+	// Check the limit before the request's params are resolved, so requests
+	// over it are turned away without doing any lookups. Key is passed nil
+	// params, so only set it if Key doesn't use them. It's always on if Key
+	// is nil.
+	BeforeParams bool
+
+	// Where the buckets are kept. Nil means a new MemoryRateLimitStore. If
+	// several RateLimiters share a store, make sure their keys differ.
+	Store RateLimitStore
+}
+
+// A TokenBucket describes a bucket that holds up to Burst tokens, and is
+// topped up at Rate tokens a second. Each request takes a token, and requests
+// are rejected when the bucket's empty.
+type TokenBucket struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed    bool          // Whether there was a token to take.
+	Remaining  int           // How many whole tokens are left.
+	RetryAfter time.Duration // How long until there's a token to take, if there wasn't one.
+	Reset      time.Duration // How long until the bucket's full again.
+}
+
+// The RateLimitStore interface is where a RateLimiter keeps its buckets. Use
+// MemoryRateLimitStore for one server, or implement it on top of something
+// like Redis to share limits between servers.
+//
+// Take takes a token from key's bucket (making a full one if there isn't one)
+// and reports what happened. It's called concurrently, so it needs to be safe
+// for that. If it returns an error, the router responds to it.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, bucket TokenBucket) (RateLimitResult, error)
+}
+
+// A RateLimiter limits how often each client can make requests to the routes
+// it's passed to (with RateLimit), using a token bucket for each key. Requests
+// over the limit get a 429 Too Many Requests.
+//
+// Because it can run once the request's params are resolved, requests can be
+// limited by what they resolved to (like the user or API key) rather than by
+// IP address. Limits that don't need the params (like the default, by IP
+// address) are checked before they're resolved instead.
+//
+// Responses from a limited route have RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, and 429s have a Retry-After header too.
+type RateLimiter struct {
+	bucket       TokenBucket
+	key          RateLimitKeyFunc
+	beforeParams bool // Whether to check the limit before resolving params.
+	store        RateLimitStore
+}
+
+// NewRateLimiter makes a RateLimiter. It panics if opts.Requests or opts.Per
+// aren't positive.
+func NewRateLimiter(opts RateLimitOptions) *RateLimiter {
+
+	if opts.Requests < 1 || opts.Per <= 0 {
+		panic(errMisconfigured("a RateLimiter needs at least 1 request per a positive period"))
+	}
+
+	if opts.Burst <= 0 {
+		opts.Burst = opts.Requests
+	}
+	if opts.Key == nil {
+		opts.Key = RemoteIP
+		opts.BeforeParams = true
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore()
+	}
+
+	return &RateLimiter{
+		bucket:       TokenBucket{Rate: float64(opts.Requests) / opts.Per.Seconds(), Burst: opts.Burst},
+		key:          opts.Key,
+		beforeParams: opts.BeforeParams,
+		store:        opts.Store,
+	}
+
+}
+
+// RateLimit limits the route with limiter, alongside any other routes it's
+// passed to (they share buckets). A route can have several rate limiters, e.g.
+// one per user and one per IP address, and requests have to get past all of
+// them.
+func RateLimit(limiter *RateLimiter) RouteOption {
+	return func(r *route) {
+		r.rateLimiters = append(r.rateLimiters, limiter)
+	}
+}
+
+// RemoteIP is a RateLimitKeyFunc that limits requests by the client's IP
+// address, from the request's RemoteAddr. If you're behind a proxy, you'll
+// want a key func that reads the address from a header it sets instead.
+func RemoteIP(r *http.Request, params RouteParams) (string, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr, nil
+	}
+	return host, nil
+}
+
+// take takes a token for req, setting the RateLimit-* headers on header. It
+// returns errRateLimited if there wasn't one.
+func (l *RateLimiter) take(req *http.Request, params RouteParams, header http.Header) error {
+
+	key, err := l.key(req, params)
+	if err != nil || key == "" {
+		return err
+	}
+
+	result, err := l.store.Take(req.Context(), key, l.bucket)
+	if err != nil {
+		return err
+	}
+
+	limited := errRateLimited{limit: l.bucket.Burst, result: result}
+	if !result.Allowed {
+		return limited
+	}
+
+	limited.setHeaders(header)
+	return nil
+
+}
+
+// checkRateLimits takes a token from each of the route's rate limiters that
+// are checked before params are resolved (if beforeParams is true, in which
+// case params is nil) or after (if it's false).
+func (r *route) checkRateLimits(req *http.Request, params RouteParams, header http.Header, beforeParams bool) error {
+	for _, limiter := range r.rateLimiters {
+		if limiter.beforeParams != beforeParams {
+			continue
+		}
+		if err := limiter.take(req, params, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errRateLimited is the error for a request turned away by a RateLimiter.
+type errRateLimited struct {
+	limit  int
+	result RateLimitResult
+}
+
+func (e errRateLimited) Error() string {
+	return "too many requests"
+}
+
+func (e errRateLimited) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+func (e errRateLimited) setHeaders(header http.Header) {
+	header.Set("RateLimit-Limit", strconv.Itoa(e.limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(e.result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(e.result.Reset)))
+	if !e.result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(e.result.RetryAfter)))
+	}
+}
+
+// ceilSeconds is d in whole seconds, rounded up, for headers.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// How often a MemoryRateLimitStore clears out full buckets.
+const memoryStoreSweepInterval = time.Minute
+
+// A MemoryRateLimitStore keeps buckets in memory, so limits only apply to the
+// one server. Buckets that have filled back up are cleared out every so often,
+// since they're the same as not having one.
+type MemoryRateLimitStore struct {
+	now func() time.Time // Swapped out in tests.
+
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	swept   time.Time // When full buckets were last cleared out.
+}
+
+// A memoryBucket is a bucket's state at the time it was last updated.
+type memoryBucket struct {
+	TokenBucket
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryRateLimitStore makes an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		now:     time.Now,
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+// Take takes a token from key's bucket. It never returns an error.
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, bucket TokenBucket) (RateLimitResult, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{TokenBucket: bucket, tokens: float64(bucket.Burst)}
+		s.buckets[key] = b
+	} else {
+		b.TokenBucket = bucket
+		b.tokens = b.tokensAt(now)
+	}
+	b.updated = now
+
+	var result RateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / b.Rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsDuration((float64(b.Burst) - b.tokens) / b.Rate)
+
+	return result, nil
+
+}
+
+// Len is how many buckets the store has.
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// sweep clears out full buckets, if it's been long enough since it last did.
+// Call it with mu locked.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+
+	if now.Sub(s.swept) < memoryStoreSweepInterval {
+		return
+	}
+	s.swept = now
+
+	for key, b := range s.buckets {
+		if b.tokensAt(now) >= float64(b.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+
+}
+
+// tokensAt is how many tokens the bucket will have at now.
+func (b *memoryBucket) tokensAt(now time.Time) float64 {
+	return math.Min(float64(b.Burst), b.tokens+now.Sub(b.updated).Seconds()*b.Rate)
+}
+
+// secondsDuration converts a float number of seconds to a Duration.
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
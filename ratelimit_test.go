@@ -0,0 +1,284 @@
+package orbit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A RateLimitStore that always fails.
+type testFailingStore struct{}
+
+func (testFailingStore) Take(ctx context.Context, key string, bucket TokenBucket) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store is down")
+}
+
+func Test_Router_RateLimit_ByParam(t *testing.T) {
+
+	// setup
+	limiter := NewRateLimiter(RateLimitOptions{
+		Requests: 2,
+		Per:      time.Hour,
+		Key: func(r *http.Request, params RouteParams) (string, error) {
+			return string(params["user"].(testTypeString)), nil
+		},
+	})
+
+	r := NewRouter()
+	r.Handle("/{user}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		w.WriteHeader(http.StatusOK)
+	}), nil, RouteParams{"user": testTypeString("")}, nil, RateLimit(limiter))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// do
+	first, second, third := serve("/alice"), serve("/alice"), serve("/alice")
+	other := serve("/bob")
+
+	// check
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "2", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", first.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1800", first.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, first.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "0", second.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusTooManyRequests, third.Code)
+	assert.Equal(t, "0", third.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1800", third.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, other.Code, "other users have their own bucket")
+
+}
+
+func Test_Router_RateLimit_HandlerNotCalled(t *testing.T) {
+
+	// setup
+	calls := 0
+	limiter := NewRateLimiter(RateLimitOptions{Requests: 1, Per: time.Hour})
+	r := NewRouter()
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		calls++
+	}), nil, nil, nil, RateLimit(limiter))
+
+	// do
+	for i := 0; i < 3; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+	}
+
+	// check
+	assert.Equal(t, 1, calls)
+
+}
+
+func Test_Router_RateLimit_BeforeParams(t *testing.T) {
+
+	testCases := []struct {
+		name string
+		opts RateLimitOptions
+	}{
+		{name: "default key", opts: RateLimitOptions{Requests: 1, Per: time.Hour}},
+		{name: "own key", opts: RateLimitOptions{Requests: 1, Per: time.Hour, BeforeParams: true, Key: func(r *http.Request, params RouteParams) (string, error) {
+			assert.Nil(t, params, "params shouldn't be resolved yet")
+			return "everyone", nil
+		}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// setup
+			calls := &atomic.Int32{}
+			r := NewRouter()
+			r.Handle("/{thing}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+				w.WriteHeader(http.StatusOK)
+			}), nil, RouteParams{"thing": testTypeExpensive{calls: calls}}, nil, RateLimit(NewRateLimiter(tc.opts)))
+
+			// do
+			first := httptest.NewRecorder()
+			r.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/abc", nil))
+			second := httptest.NewRecorder()
+			r.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+			// check
+			assert.Equal(t, http.StatusOK, first.Code)
+			assert.Equal(t, http.StatusTooManyRequests, second.Code)
+			assert.Equal(t, int32(1), calls.Load(), "limited request shouldn't resolve its params")
+
+		})
+	}
+
+}
+
+func Test_Router_RateLimit_BeforeConcurrencyLimit(t *testing.T) {
+
+	// setup - the first request holds the route's only slot until it's told to finish
+	started, finish := make(chan struct{}), make(chan struct{})
+	r := NewRouter()
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		close(started)
+		<-finish
+	}), nil, nil, nil, MaxInFlight(1), RateLimit(NewRateLimiter(RateLimitOptions{Requests: 1, Per: time.Hour})))
+
+	go r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+	<-started
+	defer close(finish)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "rate limit should be checked before waiting for a slot")
+
+}
+
+func Test_Router_RateLimit_EmptyKey(t *testing.T) {
+
+	// setup
+	limiter := NewRateLimiter(RateLimitOptions{
+		Requests: 1,
+		Per:      time.Hour,
+		Key:      func(r *http.Request, params RouteParams) (string, error) { return "", nil },
+	})
+	r := NewRouter()
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		w.WriteHeader(http.StatusOK)
+	}), nil, nil, nil, RateLimit(limiter))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+
+}
+
+func Test_Router_RateLimit_Errors(t *testing.T) {
+
+	testCases := []struct {
+		name         string
+		opts         RateLimitOptions
+		expectStatus int
+	}{
+		{
+			name: "key error",
+			opts: RateLimitOptions{Requests: 1, Per: time.Hour, Key: func(r *http.Request, params RouteParams) (string, error) {
+				return "", Forbidden("no API key")
+			}},
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "store error",
+			opts:         RateLimitOptions{Requests: 1, Per: time.Hour, Store: testFailingStore{}},
+			expectStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// setup
+			r := NewRouter()
+			r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+				t.Error("handler shouldn't be called")
+			}), nil, nil, nil, RateLimit(NewRateLimiter(tc.opts)))
+
+			// do
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+			// check
+			assert.Equal(t, tc.expectStatus, w.Code)
+
+		})
+	}
+
+}
+
+func Test_RemoteIP(t *testing.T) {
+
+	// setup
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+
+	// do
+	key, err := RemoteIP(req, nil)
+
+	// check
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", key)
+
+}
+
+func Test_MemoryRateLimitStore_Refill(t *testing.T) {
+
+	// setup
+	now := time.Unix(0, 0)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	bucket := TokenBucket{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	// do, check
+	result, _ := store.Take(ctx, "a", bucket)
+	assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 1, Reset: time.Second}, result)
+
+	result, _ = store.Take(ctx, "a", bucket)
+	assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 0, Reset: 2 * time.Second}, result)
+
+	now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take(ctx, "a", bucket)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take(ctx, "a", bucket)
+	assert.True(t, result.Allowed, "a token should've been added after a second")
+
+}
+
+func Test_MemoryRateLimitStore_Sweep(t *testing.T) {
+
+	// setup
+	now := time.Unix(0, 0)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	bucket := TokenBucket{Rate: 1, Burst: 1}
+	ctx := context.Background()
+
+	store.Take(ctx, "a", bucket)
+	now = now.Add(memoryStoreSweepInterval / 2)
+	store.Take(ctx, "b", bucket)
+
+	// do
+	now = now.Add(memoryStoreSweepInterval)
+	store.Take(ctx, "c", bucket)
+
+	// check
+	assert.Equal(t, 1, store.Len(), "full buckets should've been cleared out")
+
+}
+
+func Test_NewRateLimiter_Invalid(t *testing.T) {
+
+	// do, check
+	assert.Panics(t, func() { NewRateLimiter(RateLimitOptions{Per: time.Second}) })
+	assert.Panics(t, func() { NewRateLimiter(RateLimitOptions{Requests: 1}) })
+
+}
@@ -0,0 +1,82 @@
+package orbit
+
+import "io"
+
+// The Releasable interface is an optional extra for param types whose resolved
+// values hold on to something for the length of a request, like a database
+// transaction, a lock, or an object checked out of a pool.
+//
+// If a resolved param implements it, Orbit calls Release once the request's
+// been handled, whether or not that went well, including if something panicked
+// or a later param or the body failed to resolve. err is nil if the request
+// was handled successfully, or the error that stopped it, so a transaction
+// can commit or roll back:
+//
+//	func (tx Tx) Release(err error) {
+//		if err != nil {
+//			tx.Rollback()
+//			return
+//		}
+//		tx.Commit()
+//	}
+//
+// Params are released in the reverse of the order they were resolved in, so
+// params that depend on others are released first. Resolved params that are
+// an io.Closer instead get Close called.
+//
+// Note that plain Handlers can't fail as far as Orbit's concerned, so if you
+// want to roll back when your handler goes wrong, use an ErrorReturningHandler
+// (like ErrorHandlerFunc) and return the error.
+type Releasable interface {
+	Release(err error)
+}
+
+// release releases value (see Releasable) if it needs releasing.
+func release(value any, outcome error) {
+	switch value := value.(type) {
+	case Releasable:
+		value.Release(outcome)
+	case io.Closer:
+		value.Close()
+	}
+}
+
+// acquired records that value has been resolved for this request, so it's
+// released when the request's done (see releaseAll). If the request's already
+// done, it's released straight away.
+func (c *requestCache) acquired(value FromRequestable) {
+
+	switch value.(type) {
+	case Releasable, io.Closer:
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	if c.finished {
+		outcome := c.outcome
+		c.mu.Unlock()
+		release(value, outcome)
+		return
+	}
+	c.toRelease = append(c.toRelease, value)
+	c.mu.Unlock()
+
+}
+
+// releaseAll releases everything resolved for this request, in the reverse
+// of the order it was resolved in, telling each how the request went.
+func (c *requestCache) releaseAll(outcome error) {
+
+	c.mu.Lock()
+	toRelease := c.toRelease
+	c.toRelease = nil
+	c.finished = true
+	c.outcome = outcome
+	c.mu.Unlock()
+
+	for i := len(toRelease) - 1; i >= 0; i-- {
+		release(toRelease[i], outcome)
+	}
+
+}
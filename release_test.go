@@ -0,0 +1,206 @@
+package orbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A log of what's been released, and how.
+type testReleaseLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *testReleaseLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+// A param type that records being released. Values of "invalid" fail validation.
+type testTypeReleasable struct {
+	log   *testReleaseLog
+	value string
+}
+
+func (x testTypeReleasable) FromRequest(param string) (any, error) {
+	if param == "bad" {
+		return nil, BadRequest("bad")
+	}
+	return testTypeReleasable{log: x.log, value: param}, nil
+}
+
+func (x testTypeReleasable) Release(err error) {
+	x.log.add(fmt.Sprintf("%s:%v", x.value, err != nil))
+}
+
+func (x testTypeReleasable) Validate() error {
+	if x.value == "invalid" {
+		return errors.New("invalid")
+	}
+	return nil
+}
+
+// A param type that's an io.Closer.
+type testTypeCloser struct {
+	log   *testReleaseLog
+	value string
+}
+
+func (x testTypeCloser) FromRequest(param string) (any, error) {
+	return testTypeCloser{log: x.log, value: param}, nil
+}
+
+func (x testTypeCloser) Close() error {
+	x.log.add("closed " + x.value)
+	return nil
+}
+
+// newReleaseRouter builds a router with a route /{a}/{b} whose params are
+// both testTypeReleasables logging to log.
+func newReleaseRouter(log *testReleaseLog, handler Handler, bodyType FromBodyable, opts ...RouteOption) Router {
+	r := NewRouter()
+	r.Handle("/{a}/{b}", handler, nil, RouteParams{
+		"a": testTypeReleasable{log: log},
+		"b": testTypeReleasable{log: log},
+	}, bodyType, opts...)
+	return r
+}
+
+func Test_Router_Release_Success(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	r := newReleaseRouter(log, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		log.add("handler")
+	}), nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/one/two", nil))
+
+	// check - released after the handler, in reverse order, successfully
+	assert.Equal(t, []string{"handler", "two:false", "one:false"}, log.entries)
+
+}
+
+func Test_Router_Release_HandlerError(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	r := newReleaseRouter(log, ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+		return Conflict("nope")
+	}), nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/one/two", nil))
+
+	// check
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, []string{"two:true", "one:true"}, log.entries)
+
+}
+
+func Test_Router_Release_Panic(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	r := newReleaseRouter(log, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		panic("oh no")
+	}), nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/one/two", nil))
+
+	// check
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, []string{"two:true", "one:true"}, log.entries)
+
+}
+
+func Test_Router_Release_BodyFails(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	r := newReleaseRouter(log, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Error("handler shouldn't be called")
+	}), testBodyableTypeStruct{})
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/one/two", strings.NewReader("not json")))
+
+	// check
+	assert.Equal(t, []string{"two:true", "one:true"}, log.entries)
+
+}
+
+func Test_Router_Release_LaterParamFails(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	r := newReleaseRouter(log, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Error("handler shouldn't be called")
+	}), nil)
+
+	// do - b fails to resolve, then fails validation, so only a needs releasing later
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/one/bad", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/one/invalid", nil))
+
+	// check - invalid values are released as soon as they're found to be invalid
+	assert.Equal(t, []string{"one:true", "invalid:true", "one:true"}, log.entries)
+
+}
+
+func Test_Router_Release_Parallel(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	r := newReleaseRouter(log, HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, ParallelParams(), ParamDependsOn("b", "a"))
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/one/two", nil))
+
+	// check - b depends on a, so it's released first
+	assert.Equal(t, []string{"two:false", "one:false"}, log.entries)
+
+}
+
+func Test_Router_Release_Closer(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	r := NewRouter()
+	r.Handle("/{a}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, RouteParams{"a": testTypeCloser{log: log}}, nil)
+
+	// do
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/one", nil))
+
+	// check
+	assert.Equal(t, []string{"closed one"}, log.entries)
+
+}
+
+func Test_requestCache_acquired_AfterFinished(t *testing.T) {
+
+	// setup
+	log := &testReleaseLog{}
+	cache := newRequestCache(map[string]string{"a": "late"})
+	cache.releaseAll(nil)
+
+	// do - e.g. a goroutine started by the handler resolving a param after it returned
+	_, err := cache.resolve(withRequestCache(context.Background(), cache), "a", testTypeReleasable{log: log})
+
+	// check
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"late:false"}, log.entries)
+
+}
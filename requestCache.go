@@ -0,0 +1,157 @@
+package orbit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// A requestCache memoises param resolution for a single request, so that if
+// the same param is needed as the same type more than once (e.g. by the
+// router and then again by something the handler calls) FromRequest only
+// runs once.
+//
+// It lives in the request's context while the request is being handled.
+type requestCache struct {
+	tokens  map[string]string // The raw param values from the request path.
+	mu      sync.Mutex        // Guards entries, and everything to do with releasing.
+	entries map[cacheKey]*cacheEntry
+
+	// Resolved values that need releasing once the request's finished (see
+	// Releasable), in the order they were resolved.
+	toRelease []FromRequestable
+	finished  bool  // Whether the request's finished, and toRelease has been released.
+	outcome   error // How the request went, once it's finished.
+
+	// The route's params, and the order they're in in the path, so params
+	// that depend on the ones before them can get at them. Set by newFromRequest.
+	declared  RouteParams
+	pathOrder []string
+}
+
+// Entries are keyed by the param's name and the type it resolves to.
+type cacheKey struct {
+	name string
+	typ  reflect.Type
+}
+
+// A cacheEntry is one resolved (or failed) param. The once makes sure it's
+// only resolved once, even if it's asked for from several goroutines at once.
+type cacheEntry struct {
+	once  sync.Once
+	value FromRequestable
+	err   error
+}
+
+// requestCacheCtxKey is the context key the requestCache is stored under.
+type requestCacheCtxKey struct{}
+
+// newRequestCache makes an empty cache for a request whose path contained the
+// given param values.
+func newRequestCache(tokens map[string]string) *requestCache {
+	return &requestCache{
+		tokens:  tokens,
+		entries: make(map[cacheKey]*cacheEntry),
+	}
+}
+
+// withRequestCache returns a copy of ctx carrying cache.
+func withRequestCache(ctx context.Context, cache *requestCache) context.Context {
+	return context.WithValue(ctx, requestCacheCtxKey{}, cache)
+}
+
+// requestCacheFrom returns the cache stored in ctx, or nil if there isn't one.
+func requestCacheFrom(ctx context.Context) *requestCache {
+	cache, _ := ctx.Value(requestCacheCtxKey{}).(*requestCache)
+	return cache
+}
+
+// resolve resolves the param called name as the type of el, or returns the
+// result from last time if it's already been resolved as that type. ctx is
+// passed to FromRequestContext if el has it.
+func (c *requestCache) resolve(ctx context.Context, name string, el FromRequestable) (FromRequestable, error) {
+
+	key := cacheKey{name: name, typ: paramType(el)}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		raw, ok := c.tokens[name]
+		if !ok {
+			entry.err = ParamError{Name: name, Err: fmt.Errorf("request has no param called %s", name)}
+			return
+		}
+
+		var earlier RouteParams
+		if _, ok := el.(FromRequestWithParamsable); ok {
+			if earlier, entry.err = c.paramsBefore(ctx, name); entry.err != nil {
+				return
+			}
+		}
+
+		entry.value, entry.err = resolveParam(ctx, name, raw, el, earlier)
+		if entry.err == nil {
+			c.acquired(entry.value)
+		}
+	})
+
+	return entry.value, entry.err
+
+}
+
+// paramsBefore resolves (or gets from the cache) every param before name in
+// the route's path, as the types the route declares for them.
+func (c *requestCache) paramsBefore(ctx context.Context, name string) (RouteParams, error) {
+
+	earlier := make(RouteParams)
+
+	for _, key := range c.pathOrder {
+		if key == name {
+			break
+		}
+
+		result, err := c.resolve(ctx, key, c.declared[key])
+		if err != nil {
+			return nil, err
+		}
+		earlier[key] = result
+	}
+
+	return earlier, nil
+
+}
+
+// ResolveParam resolves one of the current request's path params as the type
+// of as. It's memoised per request, so if Orbit (or anything else) has already
+// resolved that param as that type while handling this request, you get the
+// same result back without FromRequest running again.
+//
+// That means expensive resolutions (like loading a User from the database)
+// happen exactly once per request, however many places need them:
+//
+//	u, err := orbit.ResolveParam(r.Context(), "user", User{})
+//	user := u.(User)
+//
+// ctx must be the context of a request being handled by an Orbit router (or
+// one derived from it). You can resolve a param as a different type to the
+// one its route declares, e.g. as a BasicString to get the raw value.
+//
+// Don't use it to resolve a param from inside that same param's FromRequest
+// (even indirectly), since it'll wait forever for itself to finish.
+func ResolveParam(ctx context.Context, name string, as FromRequestable) (FromRequestable, error) {
+
+	cache := requestCacheFrom(ctx)
+	if cache == nil {
+		return nil, errMisconfigured("ResolveParam needs the context of a request being handled by orbit")
+	}
+
+	return cache.resolve(ctx, name, as)
+
+}
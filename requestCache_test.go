@@ -0,0 +1,136 @@
+package orbit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Dummy type that counts how many times FromRequest is called
+type testTypeCounted struct {
+	calls *atomic.Int32
+	value string
+}
+
+func (x testTypeCounted) FromRequest(param string) (any, error) {
+	x.calls.Add(1)
+	return testTypeCounted{calls: x.calls, value: param}, nil
+}
+
+func Test_requestCache_resolve_Memoised(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	cache := newRequestCache(map[string]string{"foo": "hello"})
+
+	// do
+	first, err1 := cache.resolve(context.Background(), "foo", testTypeCounted{calls: calls})
+	second, err2 := cache.resolve(context.Background(), "foo", testTypeCounted{calls: calls})
+
+	// check
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, "hello", first.(testTypeCounted).value)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), calls.Load())
+
+}
+
+func Test_requestCache_resolve_KeyedByType(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	cache := newRequestCache(map[string]string{"foo": "123"})
+
+	// do
+	counted, err1 := cache.resolve(context.Background(), "foo", testTypeCounted{calls: calls})
+	asInt, err2 := cache.resolve(context.Background(), "foo", testTypeInt(0))
+
+	// check
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, "123", counted.(testTypeCounted).value)
+	assert.Equal(t, testTypeInt(123), asInt)
+
+}
+
+func Test_requestCache_resolve_Concurrent(t *testing.T) {
+
+	// setup
+	calls := &atomic.Int32{}
+	cache := newRequestCache(map[string]string{"foo": "hello"})
+
+	// do
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.resolve(context.Background(), "foo", testTypeCounted{calls: calls})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// check
+	assert.Equal(t, int32(1), calls.Load())
+
+}
+
+func Test_requestCache_resolve_MissingParam(t *testing.T) {
+
+	cache := newRequestCache(map[string]string{"foo": "hello"})
+
+	_, err := cache.resolve(context.Background(), "bar", testTypeString(""))
+
+	assert.Error(t, err)
+
+}
+
+func Test_ResolveParam_NoCache(t *testing.T) {
+
+	_, err := ResolveParam(context.Background(), "foo", testTypeString(""))
+
+	assert.Error(t, err)
+
+}
+
+func Test_ResolveParam_E2E(t *testing.T) {
+
+	// Flag - set true if the handler gets called (we want it to be called)
+	handlerWasCalled := false
+	calls := &atomic.Int32{}
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+
+		// Resolving the param again should give the same value without
+		// calling FromRequest again.
+		resolved, err := ResolveParam(r.Context(), "foo", testTypeCounted{})
+		assert.NoError(t, err)
+		assert.Equal(t, params["foo"], resolved)
+
+		// Resolving it as a different type should work too.
+		raw, err := ResolveParam(r.Context(), "foo", BasicString(""))
+		assert.NoError(t, err)
+		assert.Equal(t, BasicString("hello"), raw)
+	})
+
+	// Build a router, add the handler, bake
+	r := NewRouter()
+	r.Handle("/a/{foo}", handler, nil, RouteParams{"foo": testTypeCounted{calls: calls}}, nil)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	// Handle the request
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/hello", nil))
+
+	// Check handler got called, and FromRequest only happened once
+	assert.True(t, handlerWasCalled, "looks like handler didn't get called")
+	assert.Equal(t, int32(1), calls.Load())
+
+}
@@ -0,0 +1,232 @@
+package orbit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The ToResponsable interface is the response-side counterpart to
+// FromBodyable. It lets a value write itself to a response.
+//
+// Handlers made with ResponderFunc return a ToResponsable instead of writing
+// to the http.ResponseWriter themselves. If ToResponse returns an error before
+// writing anything, Orbit responds with that error instead (see Error).
+type ToResponsable interface {
+	ToResponse(http.ResponseWriter, *http.Request) error
+}
+
+// Response is a ToResponsable that responds with Status, and Body encoded in
+// whichever format the request's Accept header prefers (see Respond).
+//
+//	return orbit.Response{Status: 200, Body: user}, nil
+type Response struct {
+	Status int // The status code to respond with. Zero means 200.
+	Body   any // The value to encode as the body, or nil for no body.
+}
+
+// ToResponse writes the Response with Respond.
+func (res Response) ToResponse(w http.ResponseWriter, r *http.Request) error {
+
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if res.Body == nil {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	return Respond(w, r, status, res.Body)
+
+}
+
+// ResponderFunc is an adapter for handlers that return their response rather
+// than writing it. If the handler returns an error, Orbit responds with it in
+// the same way as it does for errors from FromRequest and FromBody, so a 4xx
+// Error's message is sent to the client and anything else is a 503.
+//
+//	orbit.ResponderFunc(func(r *http.Request, p orbit.RouteParams, b orbit.FromBodyable) (orbit.ToResponsable, error) {
+//		user := p["user"].(User)
+//		if !user.Active {
+//			return nil, orbit.NotFound("no such user")
+//		}
+//		return orbit.Response{Body: user}, nil
+//	})
+type ResponderFunc func(*http.Request, RouteParams, FromBodyable) (ToResponsable, error)
+
+// ServeHTTP calls f, and writes its response (or error) to w.
+func (f ResponderFunc) ServeHTTP(w http.ResponseWriter, r *http.Request, p RouteParams, b FromBodyable) {
+	if err := f.ServeHTTPWithError(w, r, p, b); err != nil {
+		writeErrorResponse(w, err)
+	}
+}
+
+// ServeHTTPWithError calls f, and writes its response to w. If f returns an
+// error, nothing is written and the error is returned.
+func (f ResponderFunc) ServeHTTPWithError(w http.ResponseWriter, r *http.Request, p RouteParams, b FromBodyable) error {
+
+	res, err := f(r, p, b)
+	if err != nil {
+		return err
+	}
+
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return res.ToResponse(w, r)
+
+}
+
+// JSON writes v to w as JSON, with the given status and a JSON Content-Type.
+func JSON(w http.ResponseWriter, status int, v any) error {
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(encoded)
+	return err
+
+}
+
+// XML writes v to w as XML, with the given status and an XML Content-Type.
+func XML(w http.ResponseWriter, status int, v any) error {
+
+	encoded, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write([]byte(xml.Header))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+
+}
+
+// Text writes v to w as plain text (formatted with fmt.Sprint), with the given
+// status and a plain text Content-Type.
+func Text(w http.ResponseWriter, status int, v any) error {
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := fmt.Fprint(w, v)
+	return err
+
+}
+
+// responseEncoders are the formats Respond can encode to, by media type, in
+// order of preference for when the client doesn't mind.
+var responseEncoders = []struct {
+	mediaType string
+	encode    func(http.ResponseWriter, int, any) error
+}{
+	{mediaType: "application/json", encode: JSON},
+	{mediaType: "application/xml", encode: XML},
+	{mediaType: "text/xml", encode: XML},
+	{mediaType: "text/plain", encode: Text},
+}
+
+// Respond writes v to w in whichever format the request's Accept header
+// prefers out of JSON, XML and plain text. Requests with no Accept header get
+// JSON.
+//
+// If the client won't accept any of those, nothing is written and a 406 Not
+// Acceptable Error is returned.
+func Respond(w http.ResponseWriter, r *http.Request, status int, v any) error {
+
+	accepted, rejected := parseAccept(r.Header.Get("Accept"))
+
+	for _, accept := range accepted {
+		for _, encoder := range responseEncoders {
+			if mediaTypeMatches(accept, encoder.mediaType) && !rejected[encoder.mediaType] {
+				w.Header().Add("Vary", "Accept")
+				return encoder.encode(w, status, v)
+			}
+		}
+	}
+
+	return Error{Status: http.StatusNotAcceptable, Message: "can't respond in any of the formats in the Accept header"}
+
+}
+
+// parseAccept returns the media types in an Accept header, most preferred
+// first, and the set of media types the header explicitly rules out (with a q
+// of 0). An empty header accepts anything.
+func parseAccept(header string) ([]string, map[string]bool) {
+
+	rejected := make(map[string]bool)
+
+	if strings.TrimSpace(header) == "" {
+		return []string{"*/*"}, rejected
+	}
+
+	type weighted struct {
+		mediaType string
+		q         float64
+	}
+	var types []weighted
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			rejected[mediaType] = true
+			continue
+		}
+
+		types = append(types, weighted{mediaType: mediaType, q: q})
+	}
+
+	// Sort by q, keeping the header's order for ties.
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].q > types[j].q
+	})
+
+	result := make([]string, len(types))
+	for idx, t := range types {
+		result[idx] = t.mediaType
+	}
+	return result, rejected
+
+}
+
+// mediaTypeMatches checks whether an accepted media type (which may contain
+// wildcards, like text/* or */*) matches a concrete one.
+func mediaTypeMatches(accepted string, mediaType string) bool {
+
+	if accepted == "*/*" || accepted == mediaType {
+		return true
+	}
+
+	acceptedType, acceptedSubtype, _ := strings.Cut(accepted, "/")
+	concreteType, _, _ := strings.Cut(mediaType, "/")
+
+	return acceptedSubtype == "*" && acceptedType == concreteType
+
+}
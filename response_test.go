@@ -0,0 +1,193 @@
+package orbit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testResponseStruct struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func Test_JSON(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	err := JSON(w, 201, testResponseStruct{Name: "joe"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"name": "joe"}`, w.Body.String())
+
+}
+
+func Test_JSON_Unencodable(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	err := JSON(w, 200, make(chan int))
+
+	assert.Error(t, err)
+	assert.Empty(t, w.Header().Get("Content-Type"), "nothing should have been written")
+
+}
+
+func Test_XML(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	err := XML(w, 200, testResponseStruct{Name: "joe"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<testResponseStruct><name>joe</name></testResponseStruct>")
+
+}
+
+func Test_Text(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	err := Text(w, 200, 123)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "123", w.Body.String())
+
+}
+
+func Test_Respond(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantErr         bool
+	}{
+		{name: "no_header", accept: "", wantContentType: "application/json; charset=utf-8"},
+		{name: "anything", accept: "*/*", wantContentType: "application/json; charset=utf-8"},
+		{name: "json", accept: "application/json", wantContentType: "application/json; charset=utf-8"},
+		{name: "xml", accept: "application/xml", wantContentType: "application/xml; charset=utf-8"},
+		{name: "text_wildcard", accept: "text/*", wantContentType: "application/xml; charset=utf-8"},
+		{name: "text_plain", accept: "text/plain", wantContentType: "text/plain; charset=utf-8"},
+		{name: "q_values", accept: "application/json;q=0.5, text/plain", wantContentType: "text/plain; charset=utf-8"},
+		{name: "first_supported", accept: "image/png, application/xml", wantContentType: "application/xml; charset=utf-8"},
+		{name: "q_zero", accept: "application/json;q=0, */*;q=0.1", wantContentType: "application/xml; charset=utf-8"},
+		{name: "unsupported", accept: "image/png", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			err := Respond(w, req, 200, testResponseStruct{Name: "joe"})
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, 406, statusFor(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+		})
+	}
+}
+
+func Test_Response_ToResponse(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	err := Response{Body: testResponseStruct{Name: "joe"}}.ToResponse(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"name": "joe"}`, w.Body.String())
+
+}
+
+func Test_Response_ToResponse_NoBody(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	err := Response{Status: 202}.ToResponse(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 202, w.Code)
+	assert.Empty(t, w.Body.String())
+
+}
+
+func Test_Router_E2E_ResponderFunc(t *testing.T) {
+
+	handler := ResponderFunc(func(r *http.Request, params RouteParams, body FromBodyable) (ToResponsable, error) {
+		name := params["name"].(BasicString)
+		switch name {
+		case "missing":
+			return nil, Error{Status: 404, Message: "no such thing"}
+		case "broken":
+			return nil, errors.New("secret internal details")
+		case "empty":
+			return nil, nil
+		}
+		return Response{Status: 200, Body: testResponseStruct{Name: string(name)}}, nil
+	})
+
+	r := NewRouter()
+	r.Handle("/things/{name}", handler, nil, RouteParams{"name": BasicString("")}, nil)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	tests := []struct {
+		name       string
+		path       string
+		accept     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "ok_json", path: "/things/joe", wantStatus: 200, wantBody: `{"name":"joe"}`},
+		{name: "ok_xml", path: "/things/joe", accept: "application/xml", wantStatus: 200, wantBody: xmlHeaderPlus("<testResponseStruct><name>joe</name></testResponseStruct>")},
+		{name: "not_acceptable", path: "/things/joe", accept: "image/png", wantStatus: 406, wantBody: "can't respond in any of the formats in the Accept header\n"},
+		{name: "client_error", path: "/things/missing", wantStatus: 404, wantBody: "no such thing\n"},
+		{name: "server_error", path: "/things/broken", wantStatus: 503, wantBody: ""},
+		{name: "no_response", path: "/things/empty", wantStatus: 204, wantBody: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+
+		})
+	}
+
+}
+
+func Test_ResponderFunc_ServeHTTP(t *testing.T) {
+
+	// Called directly (not through a router), errors should still be responded to
+	handler := ResponderFunc(func(r *http.Request, params RouteParams, body FromBodyable) (ToResponsable, error) {
+		return nil, BadRequest("nope")
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil), nil, nil)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "nope\n", w.Body.String())
+
+}
+
+func xmlHeaderPlus(s string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + s
+}
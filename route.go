@@ -2,10 +2,14 @@ package orbit
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // A route is an entry in a router.
@@ -24,18 +28,32 @@ type route struct {
 	params   RouteParams  // Route parameters that'll be passed to the handler (whose types must implement FromRequestable)
 	bodyType FromBodyable // The type of the body (which will be nil if the handler doesn't care about the body or will decode its own)
 	methods  []string     // The methods to match (e.g. get/put/patch). If it's empty, match all.
+	name     string       // An optional name for the route, used to build URLs for it.
+
+	wideParams     bool                // Let params contain . ~ : and + too (see WideParams).
+	parallelParams bool                // Resolve params concurrently (see ParallelParams).
+	paramDeps      map[string][]string // Params that have to wait for other params (see ParamDependsOn).
+	timeout        time.Duration       // How long the route has to handle a request (see Timeout).
+	decodeTimeout  time.Duration       // How long the route has to resolve params and the body (see DecodeTimeout).
+	limiters       []*Limiter          // Limits on how many requests can be handled at once (see Limit).
+	rateLimiters   []*RateLimiter      // Limits on how often clients can make requests (see RateLimit).
+	cors           *CORSOptions        // The route's CORS options, or nil if it doesn't allow CORS (see CORS).
+	corsSet        bool                // Whether cors was set for the route, rather than taken from the router.
+	inFlight       *atomic.Int64       // How many requests the route's handling right now.
 	// filters []FilterFunc // Request filters that can block execution if necessary (todo)
 
 	// generated during config:
-	orderedParamNames []string      // An ordered list of params in the path
-	regex             regexp.Regexp // Precompiled regex for matching route params.
+	orderedParamNames []string                  // An ordered list of params in the path
+	regex             regexp.Regexp             // Precompiled regex for matching route params.
+	plan              *resolvePlan              // How to go about resolving the params.
+	batchers          map[reflect.Type]*batcher // A batcher for each FromRequestBatchable param type (see newBatchers).
 }
 
 // Call bake when you're done configuring the routing tree. Call it only once.
 // This 'precompiles' the handler by building the regex, param names etc.
 func (r *route) bake() error {
 
-	rx, opn, err := buildMatcherRegex(r.path)
+	rx, opn, err := buildMatcherRegex(r.path, r.paramPattern())
 	if err != nil {
 		return err
 	}
@@ -43,14 +61,45 @@ func (r *route) bake() error {
 	r.regex = *rx
 	r.orderedParamNames = opn
 
+	if r.inFlight == nil {
+		r.inFlight = &atomic.Int64{}
+	}
+	sortLimiters(r.limiters)
+
 	if len(r.orderedParamNames) != len(r.params) {
-		return errMisconfigured("number of params in url doesn't match number of types (%d vs %d)")
+		return errMisconfigured(fmt.Sprintf("number of params in url doesn't match number of types (%d vs %d)", len(r.orderedParamNames), len(r.params)))
+	}
+
+	// Every param in the path needs a type, or there'd be nothing to resolve
+	// it with.
+	for _, name := range r.orderedParamNames {
+		if _, ok := r.params[name]; !ok {
+			return errMisconfigured(fmt.Sprintf("param {%s} in url has no type in RouteParams", name))
+		}
+	}
+
+	if err := r.cors.check(); err != nil {
+		return err
 	}
 
+	plan, err := newResolvePlan(r.orderedParamNames, r.params, r.paramDeps, r.parallelParams)
+	if err != nil {
+		return err
+	}
+	r.plan = plan
+
 	return nil
 
 }
 
+// paramPattern is the regex the route's params have to match.
+func (r *route) paramPattern() string {
+	if r.wideParams {
+		return wideParamPattern
+	}
+	return paramPattern
+}
+
 // Calling ServeHTTP on a route causes it to handle the request if it matches.
 // If the path doesn't match the path fed in, then the request won't be handled.
 //
@@ -63,36 +112,147 @@ func (r *route) bake() error {
 //     intermittently - it'll either always work or never work. If you see this
 //     it means you need to check how you're setting orbit up.
 //   - Any other error - it'll bubble up errors returned by your FromRequest,
-//     FromBody, or FromHeader funcs. Up to you how you want to handle these,
-//     but best practice would probably be to return 5xx?
+//     FromBody, or FromHeader funcs, or by ErrorReturningHandlers. The router
+//     responds to these based on their status (see statusFor).
 func (r *route) ServeHTTP(w http.ResponseWriter, req http.Request) error {
 
+	paramVals, err := r.match(req)
+	if err != nil {
+		return err
+	}
+
+	return r.serve(w, req, paramVals, nil)
+
+}
+
+// match checks whether the route matches req's method and path, and if it
+// does, returns the raw values of the params in the path. If it doesn't, it
+// returns errRouteDoesNotMatch.
+func (r *route) match(req http.Request) (map[string]string, error) {
+
 	// If this handler is set to match a specific method, check that.
 	if len(r.methods) > 0 {
 		if !contains(r.methods, strings.ToUpper(req.Method)) {
-			return errRouteDoesNotMatch("wrong http verb")
+			return nil, errRouteDoesNotMatch("wrong http verb")
 		}
 	}
 
 	// Try matching against the regex and extracting params.
-	paramVals, err := tokenise(&r.regex, r.orderedParamNames, req.URL.Path)
-	if err != nil {
-		return err
+	return tokenise(&r.regex, r.orderedParamNames, req.URL.Path)
+
+}
+
+// handle handles a request that this route matched, given the raw params that
+// match extracted from it.
+//
+// If the route has timeouts, watch has the deadline for resolving the params
+// and body, and is told which stage handling the request is at (see serve).
+// Otherwise it's nil.
+func (r *route) handle(w http.ResponseWriter, req http.Request, paramVals map[string]string, watch *timeoutWatch) (err error) {
+
+	// Put a cache for resolved params in the request's context, so anything
+	// that needs the same param later (see ResolveParam) doesn't redo the work.
+	cache := newRequestCache(paramVals)
+	req = *req.WithContext(withRequestCache(req.Context(), cache))
+
+	// Once we're done, release anything resolved params are holding on to
+	// (see Releasable), telling them how it went. That includes panics, which
+	// are passed on afterwards for the router to deal with.
+	defer func() {
+		if value := recover(); value != nil {
+			cache.releaseAll(panicError(value))
+			panic(value)
+		}
+		cache.releaseAll(err)
+	}()
+
+	// Params and the body are resolved against the decode deadline, if
+	// there is one.
+	decodeReq := req
+	if watch != nil {
+		decodeReq = *req.WithContext(watch.decodeContext(req.Context()))
 	}
 
 	// Build a param map populated with the ones from this request.
 	// Note: If the params involve 'getting a user from the database based on
 	//       an an ID provided in the request' etc. this is when that happens.
-	scopedParams, err := r.params.newFromRequest(paramVals)
+	watch.setStage(stageParams)
+	scopedParams, err := r.params.newFromRequest(decodeReq.Context(), cache, r.plan)
 	if err != nil {
-		return err
+		return r.stageError(stageParams, watch.timeoutError(err))
+	}
+
+	// Rate limits that are keyed on params are checked once they're resolved,
+	// but before doing anything else. The rest were checked in serve.
+	watch.setStage(stageRateLimit)
+	if err := r.checkRateLimits(&decodeReq, *scopedParams, w.Header(), false); err != nil {
+		return r.stageError(stageRateLimit, watch.timeoutError(err))
+	}
+
+	var decodedBody FromBodyable
+	if r.bodyType != nil {
+		watch.setStage(stageBody)
+		decodedBody, err = r.decodeBody(&decodeReq)
+		if err != nil {
+			return r.stageError(stageBody, watch.timeoutError(err))
+		}
+		req.Body = decodeReq.Body
+
+		// Some bodies (like MultipartForm) hang on to things like temp files
+		// until the handler's finished with them.
+		if cleaner, ok := decodedBody.(bodyCleaner); ok {
+			defer cleaner.RemoveAll()
+		}
 	}
 
-	// If the handler isn't expecting a decoded body, we can call it now.
-	if r.bodyType == nil {
-		r.handler.ServeHTTP(w, &req, *scopedParams, nil)
+	// If we've run out of time (e.g. because a resolver ignored its
+	// context) don't start the handler, since the router's already responded.
+	if err := watch.expired(); err != nil {
+		return r.stageError(watch.stage(), err)
+	}
+
+	// Now call the handler, which will have all the params filled :)
+	watch.setStage(stageHandler)
+	return r.stageError(stageHandler, r.callHandler(w, &req, *scopedParams, decodedBody))
+
+}
+
+// stageError wraps err (if it isn't nil) with this route and the stage it
+// happened in, for logging.
+func (r *route) stageError(stage string, err error) error {
+	if err == nil {
 		return nil
 	}
+	return stageError{route: r.path, stage: stage, err: err}
+}
+
+// callHandler calls the route's handler. If it's an ErrorReturningHandler,
+// its error is returned so the router can respond to it.
+func (r *route) callHandler(w http.ResponseWriter, req *http.Request, params RouteParams, body FromBodyable) error {
+
+	if withError, ok := r.handler.(ErrorReturningHandler); ok {
+		return withError.ServeHTTPWithError(w, req, params, body)
+	}
+
+	r.handler.ServeHTTP(w, req, params, body)
+	return nil
+
+}
+
+// A bodyCleaner is a decoded body that needs tidying up once the handler is
+// done with it, like MultipartForm (or multipart.Form) and its temp files.
+type bodyCleaner interface {
+	RemoveAll() error
+}
+
+// decodeBody decodes the request's body to the route's body type.
+func (r *route) decodeBody(req *http.Request) (FromBodyable, error) {
+
+	// Body types that want the request get it as-is, so they can stream the
+	// body if they like.
+	if requestable, ok := r.bodyType.(FromBodyRequestable); ok {
+		return tryFromBodyRequest(requestable, req)
+	}
 
 	// Try decoding the request body
 	// Read the body and make 2 new readers from it, since reading once
@@ -101,18 +261,21 @@ func (r *route) ServeHTTP(w http.ResponseWriter, req http.Request) error {
 	bReader1 := io.NopCloser(bytes.NewBuffer(body))
 	bReader2 := io.NopCloser(bytes.NewBuffer(body))
 
-	decodedBody, err := tryFromBody(r.bodyType, bReader1)
+	var decodedBody FromBodyable
+	var err error
+	if withState, ok := r.bodyType.(FromBodyWithStateable); ok {
+		decodedBody, err = tryFromBodyWithState(withState, StateFrom(req.Context()), bReader1)
+	} else {
+		decodedBody, err = tryFromBody(r.bodyType, bReader1)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Set the request's body back to the second reader so it's not empty anymore.
 	req.Body = bReader2
 
-	// Now call the handler, which will have all the params filled :)
-	r.handler.ServeHTTP(w, &req, *scopedParams, decodedBody)
-
-	return nil
+	return decodedBody, nil
 
 }
 
@@ -0,0 +1,67 @@
+package orbit
+
+// A RouteOption configures an optional extra on a route.
+// Pass any number of them as the last arguments to Router.Handle.
+//
+// For example:
+//
+//	r.Handle("/user/{user}", handler, nil, params, nil, orbit.Named("user"))
+type RouteOption func(*route)
+
+// Named gives a route a name, so you can build URLs for it with Router.URL.
+// Names must be unique within a router.
+func Named(name string) RouteOption {
+	return func(r *route) {
+		r.name = name
+	}
+}
+
+// WideParams lets the route's params contain . ~ : and + as well as letters,
+// numbers, _ and -, so that values like decimals (1.5), timestamps
+// (2006-01-02T15:04:05+07:00) and durations (1.5h) can be passed as params.
+// A value of just . or .. still never matches.
+//
+// It's off by default, since it changes which requests match: with it,
+// /files/{name} matches /files/report.json, which might be meant for a
+// /files/{name}.json route. Set Router.WideParams to turn it on for every
+// route.
+func WideParams() RouteOption {
+	return func(r *route) {
+		r.wideParams = true
+	}
+}
+
+// ParallelParams makes the route resolve its params concurrently, each in its
+// own goroutine, rather than one after the other. It's worth it when several
+// params need slow lookups (e.g. a database call each).
+//
+// If any param fails, the context passed to the others' FromRequestContext is
+// cancelled, and params that haven't started yet don't start.
+//
+// Use ParamDependsOn for params that need others resolving first.
+func ParallelParams() RouteOption {
+	return func(r *route) {
+		r.parallelParams = true
+	}
+}
+
+// ParamDependsOn makes param wait for each of deps to resolve before it's
+// resolved itself, so its FromRequestContext can get at them with
+// ResolveParam. If any of deps fail, param isn't resolved at all.
+//
+// For example, for /org/{org}/project/{project} where looking up a project
+// needs the org:
+//
+//	orbit.ParamDependsOn("project", "org")
+//
+// Dependencies are respected whether or not the route uses ParallelParams.
+// Routes with dependencies that don't exist, or that go round in a circle,
+// fail to bake.
+func ParamDependsOn(param string, deps ...string) RouteOption {
+	return func(r *route) {
+		if r.paramDeps == nil {
+			r.paramDeps = make(map[string][]string)
+		}
+		r.paramDeps[param] = append(r.paramDeps[param], deps...)
+	}
+}
@@ -0,0 +1,45 @@
+package orbit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Named(t *testing.T) {
+
+	var r route
+	Named("my-route")(&r)
+
+	assert.Equal(t, "my-route", r.name)
+
+}
+
+func Test_WideParams(t *testing.T) {
+
+	var r route
+	WideParams()(&r)
+
+	assert.True(t, r.wideParams)
+	assert.Equal(t, wideParamPattern, r.paramPattern())
+
+}
+
+func Test_ParallelParams(t *testing.T) {
+
+	var r route
+	ParallelParams()(&r)
+
+	assert.True(t, r.parallelParams)
+
+}
+
+func Test_ParamDependsOn(t *testing.T) {
+
+	var r route
+	ParamDependsOn("c", "a")(&r)
+	ParamDependsOn("c", "b")(&r)
+
+	assert.Equal(t, map[string][]string{"c": {"a", "b"}}, r.paramDeps)
+
+}
@@ -219,6 +219,22 @@ func Test_Route_bake_WrongParamCount(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func Test_Route_bake_MismatchedParamNames(t *testing.T) {
+	route := route{
+		path: "/a/{b}",
+		handler: HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+			t.Fatalf("Handler was called when it shouldn't have been")
+		}),
+		params: RouteParams{
+			"c": testTypeString(""),
+		},
+	}
+
+	err := route.bake()
+	assert.ErrorContains(t, err, "{b}")
+	assert.IsType(t, errMisconfigured(""), err)
+}
+
 func Test_Route_bake_BadPath(t *testing.T) {
 	route := route{
 		path: "/a/b/{foo/d/{bar}",
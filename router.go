@@ -1,9 +1,14 @@
 package orbit
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // A Router routes an incoming request to handler, based on its path and its method.
@@ -17,13 +22,52 @@ import (
 //
 // Build the router with .Handler or .Subrouter calls
 type Router struct {
-	routes []route
-	Logger *log.Logger // If you want Orbit to log its errors somewhere, set a logger here.
+	routes    []route
+	lifecycle *lifecycle   // Shared between copies of the router, so a baked router stays baked.
+	Logger    *slog.Logger // If you want Orbit to log its errors somewhere, set a logger here.
+	AccessLog bool         // Set to also log every request (at Info) to Logger once it's been handled.
+	hooks     []Hook       // Plugins to tell about what the router's doing (see AddHook).
+
+	// How long to collect lookups for FromRequestBatchable params before
+	// resolving them together, and the most to resolve at once. Zero means
+	// DefaultBatchWindow and DefaultMaxBatchSize.
+	BatchWindow  time.Duration
+	MaxBatchSize int
+
+	// Default timeouts for routes that don't set their own (see Timeout and
+	// DecodeTimeout). Zero means no timeout.
+	Timeout       time.Duration
+	DecodeTimeout time.Duration
+
+	// CORS options for routes that don't set their own (see CORSOptions). Nil
+	// means CORS is off.
+	CORS *CORSOptions
+
+	// Let every route's params contain . ~ : and + (see WideParams).
+	WideParams bool
+
+	// By default, panics while handling a request (in FromRequest, FromBody or
+	// your handler) are recovered, logged with their stack trace and responded
+	// to with a 500. Set DisablePanicRecovery to let them through instead,
+	// e.g. so they fail your tests loudly.
+	DisablePanicRecovery bool
+}
+
+// lifecycle tracks whether a router has been baked yet.
+//
+// Router is passed around by value (e.g. into http.ListenAndServe), so this
+// lives behind a pointer to make sure every copy agrees on whether it's baked.
+type lifecycle struct {
+	once    sync.Once      // Guards baking, so it only ever happens once.
+	baked   atomic.Bool    // Set once baking has been attempted. Handle is rejected after this.
+	bakeErr error          // The result of baking, returned to anyone who tries to serve.
+	named   map[string]int // Index into the router's routes for each named route. Built during baking.
+	state   State          // Values registered with Manage.
 }
 
 // NewRouter creates a new Orbit router, off of which you can hang your handlers.
 func NewRouter() Router {
-	return Router{}
+	return Router{lifecycle: &lifecycle{}}
 }
 
 // Handle adds a new handler to the router.
@@ -32,34 +76,54 @@ func NewRouter() Router {
 //   - methods is a []string of HTTP verbs to match, or nil to match all of them
 //   - routeParamTypes a map of paramName/TypeOfParam that the handler expects, or nil if the route has no params.
 //   - bodyType is the type the body should be decoded to, or nil for orbit to skip decoding that.
+//   - opts are optional extras for the route, like a name (see Named).
 //
 // The handler will be called if:
 //   - the path matches path
 //   - the methods match
 //   - all params in the request successfully resolve to the types specified in routeParamTypes
 //   - the body successfully resolves to bodyType (if bodyType isn't nil)
+//
+// Handle panics if it's called after the router has been baked, since the
+// route table can't change once the router might be serving requests.
 func (router *Router) Handle(
 	path string,
 	handler Handler,
 	methods []string,
 	routeParamTypes RouteParams,
 	bodyType FromBodyable,
+	opts ...RouteOption,
 ) {
 
+	if router.lifecycle == nil {
+		router.lifecycle = &lifecycle{}
+	}
+
+	if router.lifecycle.baked.Load() {
+		panic(errMisconfigured(fmt.Sprintf("can't add handler '%s' after the router has been baked", path)))
+	}
+
 	// if routeParamTypes is nil, set it to an empty map.
 	paramTypes := routeParamTypes
 	if routeParamTypes == nil {
 		paramTypes = make(RouteParams)
 	}
 
-	// append it to the route
-	router.routes = append(router.routes, route{
+	newRoute := route{
 		path:     path,
 		handler:  handler,
 		methods:  methods,
 		params:   paramTypes,
 		bodyType: bodyType,
-	})
+	}
+
+	// apply any extra options
+	for _, opt := range opts {
+		opt(&newRoute)
+	}
+
+	// append it to the route
+	router.routes = append(router.routes, newRoute)
 
 }
 
@@ -67,13 +131,85 @@ func (router *Router) Handle(
 // It precompiles your routes' regexes and checks the params match up.
 //
 // Call Bake exactly once, after you have added all of your routes and before you
-// start using the router.
+// start using the router. Calling it a second time returns an error (the one
+// baking failed with, if it did).
+//
+// If you forget to call Bake, the router bakes itself when it handles its first
+// request, and responds with a 500 to everything if that fails.
 func (router *Router) Bake() error {
 
+	if router.lifecycle == nil {
+		router.lifecycle = &lifecycle{}
+	}
+
+	if router.lifecycle.baked.Load() {
+		if err := router.lifecycle.bakeErr; err != nil {
+			return err
+		}
+		return errMisconfigured("router has already been baked")
+	}
+
+	return router.bakeOnce()
+
+}
+
+// bakeOnce bakes the router's routes the first time it's called, and returns
+// the outcome of that first bake on every call.
+//
+// It's safe to call concurrently, so ServeHTTP can use it to lazily bake.
+func (router Router) bakeOnce() error {
+
+	router.lifecycle.once.Do(func() {
+		router.lifecycle.bakeErr = router.bakeRoutes()
+		router.lifecycle.baked.Store(true)
+	})
+
+	return router.lifecycle.bakeErr
+
+}
+
+// bakeRoutes does the actual work of baking. Only call it via bakeOnce.
+func (router Router) bakeRoutes() error {
+
+	named := make(map[string]int)
+
 	for i := 0; i < len(router.routes); i++ {
+		if router.routes[i].timeout == 0 {
+			router.routes[i].timeout = router.Timeout
+		}
+		if router.routes[i].decodeTimeout == 0 {
+			router.routes[i].decodeTimeout = router.DecodeTimeout
+		}
+		if !router.routes[i].corsSet {
+			router.routes[i].cors = router.CORS
+		}
+		if router.WideParams {
+			router.routes[i].wideParams = true
+		}
+
 		if err := router.routes[i].bake(); err != nil {
 			return errMisconfigured(fmt.Sprintf("couldn't bake handler '%s': %s", router.routes[i].path, err.Error()))
 		}
+
+		// Index the route by name if it has one, checking names are unique.
+		if name := router.routes[i].name; name != "" {
+			if _, exists := named[name]; exists {
+				return errMisconfigured(fmt.Sprintf("more than one route is named '%s'", name))
+			}
+			named[name] = i
+		}
+	}
+
+	router.lifecycle.named = named
+	if err := newBatchers(router.routes, router.BatchWindow, router.MaxBatchSize); err != nil {
+		return err
+	}
+
+	// Let hooks see (and change) the baked routes.
+	for _, hook := range router.hooks {
+		if err := hook.OnBake(RouteTable{routes: router.routes}); err != nil {
+			return errMisconfigured(fmt.Sprintf("hook %T failed to bake: %s", hook, err.Error()))
+		}
 	}
 
 	return nil
@@ -83,28 +219,184 @@ func (router *Router) Bake() error {
 // Handle an incoming HTTP request
 func (router Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	// Try every handler until one handles it.
-	for _, route := range router.routes {
-		err := route.ServeHTTP(w, *r)
+	// Keep track of the response, so errors can tell whether it's already
+	// started, and for the access log and hooks. Reporting it is deferred
+	// before recovering from panics, so it runs afterwards and sees the 500.
+	// It's a closure so it sees the request as changed by hooks.
+	recorder := &statusRecorder{ResponseWriter: w}
+	w = recorder
+	if (router.AccessLog && router.Logger != nil) || len(router.hooks) > 0 {
+		start := time.Now()
+		defer func() { router.finishRequest(recorder, r, start) }()
+	}
+
+	if !router.DisablePanicRecovery {
+		defer router.recoverPanic(w, &r)
+	}
 
-		// If this handler successfully handled the route, we can stop searching
-		if err == nil {
-			return
-		}
+	for _, hook := range router.hooks {
+		r = hook.OnRequest(r)
+	}
+
+	// A router with no lifecycle has never had a route added, so there's
+	// nothing to bake and nothing that could match.
+	if router.lifecycle == nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	// Make sure the routes are baked before using them. If the router was
+	// baked up front this is a no-op.
+	if err := router.bakeOnce(); err != nil {
+		router.logError(r, stageError{stage: stageBake, err: err})
+		router.callOnError(r, err)
+		w.WriteHeader(500)
+		return
+	}
+
+	// Make managed state available to resolvers and handlers.
+	if router.lifecycle.state.values != nil {
+		r = r.WithContext(withState(r.Context(), router.lifecycle.state))
+	}
+
+	// Answer CORS preflight requests from the route table.
+	if isPreflight(r) && router.servePreflight(w, r) {
+		return
+	}
+
+	// Try every handler until one matches.
+	for i := range router.routes {
+		route := &router.routes[i]
+
+		paramVals, err := route.match(*r)
 
 		// If the error is errRouteDoesNotMatch, try the next handler
 		if _, ok := err.(errRouteDoesNotMatch); ok {
 			continue
 		}
+		if err != nil {
+			router.writeError(w, r, err)
+			return
+		}
+
+		recorder.route = route
+		if len(router.hooks) > 0 {
+			info := route.info()
+			for _, hook := range router.hooks {
+				hook.OnMatch(r, info)
+			}
+		}
 
-		// If the err is any other type, stop processing handlers and log it
-		if router.Logger != nil {
-			router.Logger.Printf("orbit encountered an error handling '%s': %s\n", r.URL.Path, err.Error())
+		if route.cors != nil {
+			route.cors.decorate(w.Header(), r)
+		}
+
+		// This route matches, so it's the one to handle the request, whether
+		// or not that goes well.
+		logLate := func(err error) { router.logError(r, err) }
+		if err := route.serve(w, *r, paramVals, logLate); err != nil {
+			router.writeError(w, r, err)
 		}
-		w.WriteHeader(503)
 		return
 	}
 
 	w.WriteHeader(404)
 
 }
+
+// recoverPanic recovers from a panic while handling *r (if there is one), logs
+// it, and responds with a 500 if the response hasn't started yet. Call it
+// deferred.
+//
+// It takes a pointer to the request so it sees it as changed by hooks, since
+// it's deferred before they run.
+//
+// http.ErrAbortHandler is let through, since net/http uses it on purpose to
+// abort a response.
+func (router Router) recoverPanic(w http.ResponseWriter, r **http.Request) {
+
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	if value == http.ErrAbortHandler {
+		panic(value)
+	}
+
+	router.writeError(w, *r, panicError(value))
+
+}
+
+// panicError is the error to respond to a recovered panic with. That's usually
+// an errPanic, but panics from Lazy.MustGet carry the error they failed with.
+func panicError(value any) error {
+	if lazy, ok := value.(lazyPanic); ok {
+		return lazy.err
+	}
+	return newErrPanic(value)
+}
+
+// newErrPanic builds an errPanic for a recovered panic, unless the value is
+// already an errPanic (e.g. from a goroutine that recovered and re-reported it).
+func newErrPanic(value any) errPanic {
+	if err, ok := value.(errPanic); ok {
+		return err
+	}
+	return errPanic{value: value, stack: debug.Stack()}
+}
+
+// writeError logs an error that stopped a request being handled, and responds
+// with the appropriate status (see writeErrorResponse).
+//
+// If the response has already started (e.g. the handler wrote some of it
+// before returning an error), it's too late to change it, so the error's only
+// logged.
+func (router Router) writeError(w http.ResponseWriter, r *http.Request, err error) {
+
+	router.logError(r, err)
+	router.callOnError(r, err)
+
+	if recorder, ok := w.(*statusRecorder); ok && recorder.status != 0 {
+		return
+	}
+
+	writeErrorResponse(w, err)
+
+}
+
+// callOnError tells the router's hooks about an error handling r.
+func (router Router) callOnError(r *http.Request, err error) {
+	for _, hook := range router.hooks {
+		hook.OnError(r, err)
+	}
+}
+
+// writeErrorResponse responds to a request with the status for err (see
+// statusFor).
+//
+// Client errors (4xx) are sent with the error's message as the body so the
+// client can tell what they did wrong (for ParamErrors, only the params that
+// failed with a client error). Anything else just gets the status, since the
+// details might not be safe to share.
+func writeErrorResponse(w http.ResponseWriter, err error) {
+
+	var hs headerSetter
+	if errors.As(err, &hs) {
+		hs.setHeaders(w.Header())
+	}
+
+	status := statusFor(err)
+	if status >= 400 && status < 500 {
+		message := err.Error()
+		var cm clientMessager
+		if errors.As(err, &cm) {
+			message = cm.clientMessage()
+		}
+		http.Error(w, message, status)
+		return
+	}
+
+	w.WriteHeader(status)
+
+}
@@ -1,9 +1,12 @@
 package orbit
 
 import (
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -229,6 +232,66 @@ func Test_Router_E2E_DecodeFails(t *testing.T) {
 
 }
 
+func Test_Router_E2E_BadParam(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Fatalf("handler was called when it shouldn't have been")
+	})
+
+	// Build a router, add the handler, bake
+	r := NewRouter()
+	r.Handle(
+		"/a/{b}",
+		handler,
+		nil,
+		RouteParams{"b": BasicInt(0)},
+		nil,
+	)
+
+	err := r.Bake()
+	assert.NoError(t, err, "router bake failed")
+
+	// Handle the request
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/not-an-int", nil))
+
+	// Check Orbit returned 400, explaining why
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "'not-an-int' isn't a whole number")
+
+}
+
+func Test_Router_E2E_SeveralBadParams(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Fatalf("handler was called when it shouldn't have been")
+	})
+
+	// Build a router, add the handler, bake
+	r := NewRouter()
+	r.Handle(
+		"/{b}/{a}",
+		handler,
+		nil,
+		RouteParams{"a": BasicInt(0), "b": BasicBool(false)},
+		nil,
+	)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	// Handle the request
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/maybe/lots", nil))
+
+	// Check Orbit returned 400, listing both problems in path order
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(
+		t,
+		"couldn't get b from request ('maybe' isn't true or false); couldn't get a from request ('lots' isn't a whole number)\n",
+		w.Body.String(),
+	)
+
+}
+
 func Test_Router_E2E_Misconfiguration(t *testing.T) {
 
 	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
@@ -250,6 +313,299 @@ func Test_Router_E2E_Misconfiguration(t *testing.T) {
 
 }
 
+func Test_Router_ServeHTTP_LazilyBakes(t *testing.T) {
+
+	handlerWasCalled := false
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+	})
+
+	// Build a router and add the handler, but don't bake it
+	r := NewRouter()
+	r.Handle("/a/{b}", handler, nil, RouteParams{"b": testTypeString("")}, nil)
+
+	// Handle the request
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/hello", nil))
+
+	// Check the router baked itself and called the handler
+	assert.True(t, handlerWasCalled, "looks like handler didn't get called")
+	assert.Error(t, r.Bake(), "baking a router that's already baked itself should fail")
+
+}
+
+func Test_Router_ServeHTTP_BakeFails(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Fatalf("handler was called when it shouldn't have been")
+	})
+
+	// Build a router with a route that can't bake, and don't bake it
+	r := NewRouter()
+	r.Handle("/a/{b", handler, nil, nil, nil)
+
+	// Handle the request
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/hello", nil))
+
+	// Check Orbit returned 500, and Bake reports why
+	assert.Equal(t, 500, w.Code)
+	assert.ErrorContains(t, r.Bake(), "couldn't bake handler '/a/{b'")
+
+}
+
+func Test_Router_Bake_Twice(t *testing.T) {
+
+	r := NewRouter()
+	r.Handle("/a/b", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, nil, nil)
+
+	assert.NoError(t, r.Bake(), "router bake failed")
+	assert.Error(t, r.Bake(), "second bake should have failed")
+
+}
+
+func Test_Router_WideParams(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		wide        bool
+		expectRoute string
+		expectName  string
+	}{
+		{name: "off by default", wide: false, expectRoute: "json", expectName: "report"},
+		{name: "on", wide: true, expectRoute: "plain", expectName: "report.json"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// setup
+			var gotRoute, gotName string
+			handler := func(route string) Handler {
+				return HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+					gotRoute, gotName = route, string(params["name"].(testTypeString))
+				})
+			}
+			r := NewRouter()
+			r.WideParams = tc.wide
+			r.Handle("/files/{name}", handler("plain"), nil, RouteParams{"name": testTypeString("")}, nil)
+			r.Handle("/files/{name}.json", handler("json"), nil, RouteParams{"name": testTypeString("")}, nil)
+
+			// do
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/report.json", nil))
+
+			// check
+			assert.Equal(t, tc.expectRoute, gotRoute)
+			assert.Equal(t, tc.expectName, gotName)
+
+		})
+	}
+
+}
+
+func Test_Router_Handle_AfterBake(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+
+	r := NewRouter()
+	r.Handle("/a/b", handler, nil, nil, nil)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	assert.Panics(t, func() {
+		r.Handle("/a/c", handler, nil, nil, nil)
+	})
+
+}
+
+func Test_Router_ServeHTTP_Concurrent(t *testing.T) {
+
+	var calls atomic.Int32
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		calls.Add(1)
+	})
+
+	// Deliberately don't bake, so every goroutine races to lazily bake
+	r := NewRouter()
+	r.Handle("/a/{b}", handler, nil, RouteParams{"b": testTypeString("")}, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/hello", nil))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(50), calls.Load())
+
+}
+
+func Test_Router_E2E_ParallelParams(t *testing.T) {
+
+	handlerWasCalled := false
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+		assert.Equal(t, testTypeChild{value: "mum/kid"}, params["child"])
+		assert.Equal(t, testTypeString("other"), params["other"])
+	})
+
+	// Build a router, add the handler, bake
+	r := NewRouter()
+	r.Handle(
+		"/{child}/{parent}/{other}",
+		handler,
+		nil,
+		RouteParams{
+			"child":  testTypeChild{},
+			"parent": testTypeString(""),
+			"other":  testTypeString(""),
+		},
+		nil,
+		ParallelParams(),
+		ParamDependsOn("child", "parent"),
+	)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	// Handle the request
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/kid/mum/other", nil))
+
+	// Check handler got called
+	assert.True(t, handlerWasCalled, "looks like handler didn't get called")
+
+}
+
+func Test_Router_Bake_BadDependencies(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+
+	r := NewRouter()
+	r.Handle("/{a}", handler, nil, RouteParams{"a": testTypeString("")}, nil, ParamDependsOn("a", "nope"))
+
+	assert.Error(t, r.Bake())
+
+}
+
+// A param type whose FromRequest always panics.
+type testTypePanics struct{}
+
+func (x testTypePanics) FromRequest(param string) (any, error) {
+	panic("oh no")
+}
+
+func Test_Router_ServeHTTP_RecoversPanic(t *testing.T) {
+
+	// setup
+	var logs strings.Builder
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		panic("handler blew up")
+	})
+
+	r := NewRouter()
+	r.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	r.Handle("/a", handler, nil, nil, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, logs.String(), "handler blew up")
+	assert.Contains(t, logs.String(), "runtime/debug.Stack", "expected a stack trace in the log")
+
+}
+
+func Test_Router_ServeHTTP_RecoversPanicAfterWriting(t *testing.T) {
+
+	// setup
+	var logs strings.Builder
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("handler blew up halfway")
+	})
+
+	r := NewRouter()
+	r.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	r.Handle("/a", handler, nil, nil, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check - it's too late for a 500, but the panic's still logged
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Contains(t, logs.String(), "handler blew up halfway")
+
+}
+
+func Test_Router_ServeHTTP_RecoversParallelParamPanic(t *testing.T) {
+
+	// setup
+	handlerWasCalled := false
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+	})
+
+	r := NewRouter()
+	r.Handle(
+		"/{a}/{b}",
+		handler,
+		nil,
+		RouteParams{
+			"a": testTypeString(""),
+			"b": testTypePanics{},
+		},
+		nil,
+		ParallelParams(),
+	)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x/y", nil))
+
+	// check
+	assert.False(t, handlerWasCalled)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+}
+
+func Test_Router_ServeHTTP_PanicRecoveryDisabled(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.DisablePanicRecovery = true
+	r.Handle("/{a}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {}), nil, RouteParams{"a": testTypePanics{}}, nil)
+
+	// do / check
+	assert.PanicsWithValue(t, "oh no", func() {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	})
+
+}
+
+func Test_Router_ServeHTTP_AbortHandler(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		panic(http.ErrAbortHandler)
+	}), nil, nil, nil)
+
+	// do / check
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+	})
+
+}
+
 func Benchmark_ServeHTTP_NoRouteParams_NoBody(b *testing.B) {
 
 	// Stop bench timer while initialising
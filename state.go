@@ -0,0 +1,126 @@
+package orbit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// State holds your app's dependencies (like a database pool or a cache), keyed
+// by their type, so resolvers and handlers can get at them without globals.
+//
+// Register values with Router.Manage, and get them back with Managed. Param
+// and body types get the router's State if they implement
+// FromRequestWithStateable or FromBodyWithStateable, and anything with the
+// request's context can use StateFrom.
+//
+// In tests, build a State with NewState and pass it to your resolvers directly:
+//
+//	state := orbit.NewState(fakeUserStore{})
+//	user, err := User{}.FromRequestWithState(context.Background(), state, "5")
+type State struct {
+	values map[reflect.Type]any
+}
+
+// NewState builds a State holding values. It panics if two of the values have
+// the same type, or if any of them are nil, like Router.Manage.
+func NewState(values ...any) State {
+	state := State{}
+	for _, value := range values {
+		state.add(value)
+	}
+	return state
+}
+
+// add adds value to the state, panicking if it's nil or there's already a
+// value of its type.
+func (s *State) add(value any) {
+
+	if value == nil {
+		panic(errMisconfigured("can't manage a nil value"))
+	}
+
+	if s.values == nil {
+		s.values = make(map[reflect.Type]any)
+	}
+
+	t := reflect.TypeOf(value)
+	if _, exists := s.values[t]; exists {
+		panic(errMisconfigured(fmt.Sprintf("already managing a value of type %s", t)))
+	}
+	s.values[t] = value
+
+}
+
+// Managed gets the value of type T from state.
+//
+// T is usually the exact type you passed to Manage, but it can also be an
+// interface, in which case Managed finds the one value that implements it.
+// That lets resolvers depend on an interface (e.g. a UserStore) that you
+// satisfy with the real thing in your app and a fake in tests.
+//
+// It returns a misconfiguration error if there's no value of type T, or if T
+// is an interface that more than one value implements.
+func Managed[T any](state State) (T, error) {
+
+	var zero T
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	if value, ok := state.values[t]; ok {
+		return value.(T), nil
+	}
+
+	if t.Kind() == reflect.Interface {
+		var found []any
+		for vt, value := range state.values {
+			if vt.Implements(t) {
+				found = append(found, value)
+			}
+		}
+		if len(found) == 1 {
+			return found[0].(T), nil
+		}
+		if len(found) > 1 {
+			return zero, errMisconfigured(fmt.Sprintf("more than one managed value implements %s", t))
+		}
+	}
+
+	return zero, errMisconfigured(fmt.Sprintf("no managed value of type %s (see Router.Manage)", t))
+
+}
+
+// stateCtxKey is the context key the router's State is stored under.
+type stateCtxKey struct{}
+
+// withState returns a copy of ctx carrying state.
+func withState(ctx context.Context, state State) context.Context {
+	return context.WithValue(ctx, stateCtxKey{}, state)
+}
+
+// StateFrom gets the router's State from a request's context. If there isn't
+// one (e.g. the context didn't come from an Orbit request) it returns an empty
+// State, which Managed reports as missing everything.
+func StateFrom(ctx context.Context) State {
+	state, _ := ctx.Value(stateCtxKey{}).(State)
+	return state
+}
+
+// Manage registers value with the router, so resolvers and handlers can get it
+// back by its type (see State and Managed).
+//
+// Like Handle, Manage panics if it's called after the router has been baked.
+// It also panics if value is nil, or if the router's already managing a value
+// of the same type.
+func (router *Router) Manage(value any) {
+
+	if router.lifecycle == nil {
+		router.lifecycle = &lifecycle{}
+	}
+
+	if router.lifecycle.baked.Load() {
+		panic(errMisconfigured(fmt.Sprintf("can't manage %T after the router has been baked", value)))
+	}
+
+	router.lifecycle.state.add(value)
+
+}
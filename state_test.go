@@ -0,0 +1,209 @@
+package orbit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A dependency that resolvers get from the router's state.
+type testStore interface {
+	Name(id string) string
+}
+
+type testFakeStore struct {
+	prefix string
+}
+
+func (s testFakeStore) Name(id string) string {
+	return s.prefix + id
+}
+
+// A param type that looks itself up in a managed testStore.
+type testTypeStateful struct {
+	name string
+}
+
+func (x testTypeStateful) FromRequest(param string) (any, error) {
+	panic("FromRequest shouldn't be called when FromRequestWithState exists")
+}
+
+func (x testTypeStateful) FromRequestWithState(ctx context.Context, state State, param string) (any, error) {
+	store, err := Managed[testStore](state)
+	if err != nil {
+		return nil, err
+	}
+	return testTypeStateful{name: store.Name(param)}, nil
+}
+
+// A body type that decodes using a managed testStore.
+type testBodyStateful struct {
+	Name string `json:"name"`
+}
+
+func (x testBodyStateful) FromBody(body io.ReadCloser) (any, error) {
+	panic("FromBody shouldn't be called when FromBodyWithState exists")
+}
+
+func (x testBodyStateful) FromBodyWithState(state State, body io.ReadCloser) (any, error) {
+	store, err := Managed[testStore](state)
+	if err != nil {
+		return nil, err
+	}
+	var result testBodyStateful
+	if err := json.NewDecoder(body).Decode(&result); err != nil {
+		return nil, BadRequest(err.Error())
+	}
+	result.Name = store.Name(result.Name)
+	return result, nil
+}
+
+func Test_Managed(t *testing.T) {
+
+	// setup
+	state := NewState(testFakeStore{prefix: "fake-"}, 42)
+
+	// do / check - exact types
+	store, err := Managed[testFakeStore](state)
+	assert.NoError(t, err)
+	assert.Equal(t, testFakeStore{prefix: "fake-"}, store)
+
+	n, err := Managed[int](state)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, n)
+
+	// do / check - interfaces find the value implementing them
+	iface, err := Managed[testStore](state)
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-1", iface.Name("1"))
+
+	// do / check - missing
+	_, err = Managed[string](state)
+	assert.ErrorAs(t, err, new(errMisconfigured))
+
+	_, err = Managed[testStore](State{})
+	assert.ErrorAs(t, err, new(errMisconfigured))
+
+}
+
+func Test_Managed_Ambiguous(t *testing.T) {
+
+	// setup
+	state := NewState(testFakeStore{}, &testFakeStore{})
+
+	// do
+	_, err := Managed[testStore](state)
+
+	// check
+	assert.ErrorAs(t, err, new(errMisconfigured))
+
+}
+
+func Test_NewState_Panics(t *testing.T) {
+	assert.Panics(t, func() { NewState(1, 2) }, "duplicate types should panic")
+	assert.Panics(t, func() { NewState(nil) }, "nil should panic")
+}
+
+func Test_StateFrom_Empty(t *testing.T) {
+
+	// do
+	state := StateFrom(context.Background())
+
+	// check
+	_, err := Managed[int](state)
+	assert.Error(t, err)
+
+}
+
+func Test_Router_Manage_AfterBake(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	assert.NoError(t, r.Bake())
+
+	// do / check
+	assert.Panics(t, func() { r.Manage(1) })
+
+}
+
+func Test_Router_E2E_ManagedState(t *testing.T) {
+
+	// setup
+	handlerWasCalled := false
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerWasCalled = true
+		assert.Equal(t, testTypeStateful{name: "fake-5"}, params["user"])
+		assert.Equal(t, testBodyStateful{Name: "fake-bob"}, body)
+
+		// Handlers can get at it too
+		store, err := Managed[testStore](StateFrom(r.Context()))
+		assert.NoError(t, err)
+		assert.Equal(t, "fake-x", store.Name("x"))
+	})
+
+	r := NewRouter()
+	r.Manage(testFakeStore{prefix: "fake-"})
+	r.Handle("/user/{user}", handler, nil, RouteParams{"user": testTypeStateful{}}, testBodyStateful{})
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/user/5", strings.NewReader(`{"name": "bob"}`)))
+
+	// check
+	assert.True(t, handlerWasCalled, "looks like handler didn't get called")
+
+}
+
+// A stateful param type that waits for its context to be done.
+type testTypeStatefulSlow struct{}
+
+func (x testTypeStatefulSlow) FromRequest(param string) (any, error) {
+	panic("FromRequest shouldn't be called when FromRequestWithState exists")
+}
+
+func (x testTypeStatefulSlow) FromRequestWithState(ctx context.Context, state State, param string) (any, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func Test_Router_E2E_ManagedState_DecodeTimeout(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.Manage(testFakeStore{})
+	r.Handle("/user/{user}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Error("handler shouldn't be called")
+	}), nil, RouteParams{"user": testTypeStatefulSlow{}}, nil, DecodeTimeout(10*time.Millisecond))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/5", nil))
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "the resolver's context should be cancelled by the decode timeout")
+
+}
+
+func Test_Router_E2E_ManagedState_Missing(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.Handle("/user/{user}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Error("handler shouldn't be called")
+	}), nil, RouteParams{"user": testTypeStateful{}}, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/5", nil))
+
+	// check
+	assert.GreaterOrEqual(t, w.Code, 500)
+
+}
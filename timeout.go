@@ -0,0 +1,358 @@
+package orbit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timeout sets how long the route has to handle a request: resolving its
+// params, decoding its body and running the handler. The request's context is
+// cancelled when it runs out, and the router responds with a 504 Gateway
+// Timeout, even if the handler's still going.
+//
+// It overrides the router's Timeout. Zero means use the router's, and a
+// negative duration means the route has no timeout, whatever the router's is.
+//
+// Responses on routes with a timeout (or decode timeout) are buffered until
+// the handler's finished, so that they can be thrown away if it runs out of
+// time. That means the handler can't stream them: flushing (e.g. for
+// server-sent events) and hijacking the connection aren't supported, and
+// http.ResponseController returns http.ErrNotSupported. Turn both timeouts
+// off for routes that need to.
+func Timeout(d time.Duration) RouteOption {
+	return func(r *route) {
+		r.timeout = d
+	}
+}
+
+// DecodeTimeout sets how long the route has to resolve its params and decode
+// its body, separately from the overall Timeout. The context passed to
+// resolvers is cancelled when it runs out, the handler isn't called, and the
+// router responds with a 503 Service Unavailable.
+//
+// It overrides the router's DecodeTimeout. Zero means use the router's, and a
+// negative duration means the route has no decode timeout.
+func DecodeTimeout(d time.Duration) RouteOption {
+	return func(r *route) {
+		r.decodeTimeout = d
+	}
+}
+
+// errTimeout is the error for a request that ran out of time. It counts as
+// context.DeadlineExceeded for errors.Is.
+type errTimeout struct {
+	status int
+	what   string // What ran out of time.
+}
+
+func (e errTimeout) Error() string {
+	return fmt.Sprintf("%s timed out", e.what)
+}
+
+func (e errTimeout) StatusCode() int {
+	return e.status
+}
+
+func (e errTimeout) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+var (
+	errHandlingTimedOut = errTimeout{status: http.StatusGatewayTimeout, what: "handling the request"}
+	errDecodeTimedOut   = errTimeout{status: http.StatusServiceUnavailable, what: "resolving the request's params and body"}
+)
+
+// A timeoutWatch keeps track of a request on a route with timeouts (see
+// route.serve).
+//
+// Its methods are safe to call on a nil timeoutWatch, which means the route
+// has no timeouts.
+type timeoutWatch struct {
+	ctx     context.Context // The request's context, with the overall deadline.
+	decode  context.Context // The context params and body are resolved with, with the decode deadline.
+	current atomic.Value    // The stage the request's at (one of the stage* constants).
+}
+
+// decodeContext is the context to resolve params and the body with. It's
+// the decode deadline applied to ctx, which should be the request's context.
+func (watch *timeoutWatch) decodeContext(ctx context.Context) context.Context {
+	if watch == nil {
+		return ctx
+	}
+	return &mergedContext{Context: ctx, deadline: watch.decode}
+}
+
+// setStage records the stage the request's at.
+func (watch *timeoutWatch) setStage(stage string) {
+	if watch != nil {
+		watch.current.Store(stage)
+	}
+}
+
+// stage is the stage the request's at.
+func (watch *timeoutWatch) stage() string {
+	if watch == nil {
+		return ""
+	}
+	stage, _ := watch.current.Load().(string)
+	return stage
+}
+
+// expired returns the timeout error if either deadline has passed, or nil.
+func (watch *timeoutWatch) expired() error {
+
+	if watch == nil {
+		return nil
+	}
+
+	if watch.ctx.Err() == context.DeadlineExceeded {
+		return errHandlingTimedOut
+	}
+	if watch.decode.Err() == context.DeadlineExceeded {
+		return errDecodeTimedOut
+	}
+
+	return nil
+
+}
+
+// timeoutError returns the timeout error in place of err if a deadline has
+// passed, since that's probably why err happened. Otherwise it returns err.
+func (watch *timeoutWatch) timeoutError(err error) error {
+	if timeoutErr := watch.expired(); timeoutErr != nil {
+		return timeoutErr
+	}
+	return err
+}
+
+// A mergedContext is a request's context (with its values, like the request
+// cache), but cancelled by the decode deadline too.
+type mergedContext struct {
+	context.Context
+	deadline context.Context
+}
+
+func (c *mergedContext) Deadline() (time.Time, bool) {
+	return c.deadline.Deadline()
+}
+
+func (c *mergedContext) Done() <-chan struct{} {
+	return c.deadline.Done()
+}
+
+func (c *mergedContext) Err() error {
+	return c.deadline.Err()
+}
+
+// serve handles a request that this route matched, enforcing its limits and
+// timeouts.
+//
+// If the route has any, the request's handled in another goroutine, writing
+// to a buffer. If it finishes in time the buffer's copied to w. If it doesn't,
+// serve returns the timeout error straight away for the router to respond
+// with, and anything the handler writes afterwards is thrown away. If the
+// handler goes on to fail or panic, that's passed to logLate (if it isn't
+// nil), since it's too late to respond with it.
+func (r *route) serve(w http.ResponseWriter, req http.Request, paramVals map[string]string, logLate func(error)) error {
+
+	// Let the route's batchable params find their batchers.
+	if len(r.batchers) > 0 {
+		req = *req.WithContext(withBatchers(req.Context(), r.batchers))
+	}
+
+	// Rate limits that don't need the params are checked first, so clients
+	// over them don't take up a limiter's slots (or cost any lookups).
+	if err := r.checkRateLimits(&req, nil, w.Header(), true); err != nil {
+		return r.stageError(stageRateLimit, err)
+	}
+
+	// Wait for the route's limiters to let the request through (see Limit).
+	release, err := r.acquire(req.Context())
+	if err != nil {
+		return r.stageError(stageLimit, err)
+	}
+
+	if r.timeout <= 0 && r.decodeTimeout <= 0 {
+		defer release()
+		return r.handle(w, req, paramVals, nil)
+	}
+
+	ctx := req.Context()
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+		req = *req.WithContext(ctx)
+	}
+
+	watch := &timeoutWatch{ctx: ctx, decode: ctx}
+	if r.decodeTimeout > 0 {
+		var cancel context.CancelFunc
+		watch.decode, cancel = context.WithTimeout(ctx, r.decodeTimeout)
+		defer cancel()
+	}
+	watch.setStage(stageParams)
+
+	tw := &timeoutWriter{header: make(http.Header)}
+	done := make(chan error, 1)
+	panicked := make(chan any, 1)
+
+	go func() {
+		// The request's only finished with its limiters' slots once the
+		// handler's actually finished, even if it's timed out.
+		defer release()
+
+		// Panics are passed back to be re-raised in the router's goroutine,
+		// with the stack from where they really happened.
+		defer func() {
+			if value := recover(); value != nil {
+				switch value.(type) {
+				case lazyPanic, errPanic:
+				default:
+					if value != http.ErrAbortHandler {
+						value = newErrPanic(value)
+					}
+				}
+				panicked <- value
+			}
+		}()
+		done <- r.handle(tw, req, paramVals, watch)
+	}()
+
+	decodeDone := watch.decode.Done()
+	ctxDone := ctx.Done()
+	for {
+		select {
+		case err := <-done:
+			// If the deadline passed as the handler finished, it's a coin
+			// toss which case gets picked, so treat it as timing out either way.
+			if ctx.Err() == context.DeadlineExceeded {
+				tw.timeOut()
+				if logLate != nil && err != nil && !errors.Is(err, context.DeadlineExceeded) {
+					logLate(err)
+				}
+				return r.stageError(watch.stage(), errHandlingTimedOut)
+			}
+			tw.copyTo(w)
+			return err
+
+		case value := <-panicked:
+			panic(value)
+
+		case <-decodeDone:
+			// The decode deadline only matters until the handler starts.
+			if watch.stage() == stageHandler || watch.decode.Err() != context.DeadlineExceeded {
+				decodeDone = nil
+				continue
+			}
+			tw.timeOut()
+			go r.waitLate(done, panicked, watch, logLate)
+			return r.stageError(watch.stage(), watch.expired())
+
+		case <-ctxDone:
+			// If the request was cancelled some other way (e.g. the client
+			// went away) there's no point responding, so leave the handler to
+			// finish up.
+			if ctx.Err() != context.DeadlineExceeded {
+				ctxDone = nil
+				continue
+			}
+			tw.timeOut()
+			go r.waitLate(done, panicked, watch, logLate)
+			return r.stageError(watch.stage(), errHandlingTimedOut)
+		}
+	}
+
+}
+
+// waitLate waits for a handler that's timed out to finish, and passes
+// anything it fails or panics with to logLate. Errors from running out of
+// time aren't passed on, since the timeout's already been reported.
+func (r *route) waitLate(done <-chan error, panicked <-chan any, watch *timeoutWatch, logLate func(error)) {
+
+	if logLate == nil {
+		return
+	}
+
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			logLate(err)
+		}
+	case value := <-panicked:
+		if value != http.ErrAbortHandler {
+			logLate(r.stageError(watch.stage(), panicError(value)))
+		}
+	}
+
+}
+
+// A timeoutWriter buffers a response, so it can be thrown away if the request
+// times out before it's finished. It deliberately doesn't implement Flush,
+// Hijack or Unwrap, since none of them can work on a buffer (see Timeout).
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.status = status
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
+
+// timeOut stops anything else being written.
+func (tw *timeoutWriter) timeOut() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+}
+
+// copyTo writes the buffered response to w. If nothing was written, nothing
+// is copied, so the router can still respond with an error.
+func (tw *timeoutWriter) copyTo(w http.ResponseWriter) {
+
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	for key, values := range tw.header {
+		w.Header()[key] = values
+	}
+
+	if tw.wroteHeader {
+		w.WriteHeader(tw.status)
+		w.Write(tw.body.Bytes())
+	}
+
+}
@@ -0,0 +1,251 @@
+package orbit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A param type that waits for its context to be cancelled, or for a gate to
+// close if it's ignoring its context.
+type testTypeSlow struct {
+	ignoreContext bool
+	gate          chan struct{}
+}
+
+func (x testTypeSlow) FromRequest(param string) (any, error) {
+	return testTypeSlow{}, nil
+}
+
+func (x testTypeSlow) FromRequestContext(ctx context.Context, param string) (any, error) {
+	if x.ignoreContext {
+		<-x.gate
+		return testTypeSlow{}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func Test_Router_Timeout_Handler(t *testing.T) {
+
+	// setup
+	writeErr := make(chan error, 1)
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond) // Give the router a chance to respond first.
+		_, err := w.Write([]byte("too late"))
+		writeErr <- err
+	})
+
+	r := NewRouter()
+	r.Handle("/a", handler, nil, nil, nil, Timeout(20*time.Millisecond))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.ErrorIs(t, <-writeErr, http.ErrHandlerTimeout)
+	assert.NotContains(t, w.Body.String(), "too late")
+
+}
+
+func Test_Router_DecodeTimeout_ContextRespected(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.Handle("/{slow}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Error("handler shouldn't be called")
+	}), nil, RouteParams{"slow": testTypeSlow{}}, nil, DecodeTimeout(20*time.Millisecond))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+
+	// check
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+}
+
+func Test_Router_DecodeTimeout_ContextIgnored(t *testing.T) {
+
+	// setup
+	gate := make(chan struct{})
+	handlerCalled := &atomic.Bool{}
+	r := NewRouter()
+	r.Handle("/{slow}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		handlerCalled.Store(true)
+	}), nil, RouteParams{"slow": testTypeSlow{ignoreContext: true, gate: gate}}, nil, DecodeTimeout(20*time.Millisecond))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+	close(gate)
+
+	// check - the router didn't wait, and the handler doesn't get called late
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, handlerCalled.Load())
+
+}
+
+func Test_Router_DecodeTimeout_NotForHandler(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.Handle("/a", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		time.Sleep(40 * time.Millisecond)
+		assert.NoError(t, r.Context().Err())
+		w.WriteHeader(http.StatusAccepted)
+	}), nil, nil, nil, DecodeTimeout(10*time.Millisecond))
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	// check
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+}
+
+func Test_Router_Timeout_InTime(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.Timeout = time.Minute
+	r.Handle("/{foo}", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		w.Header().Set("X-Foo", string(params["foo"].(BasicString)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}), nil, RouteParams{"foo": BasicString("")}, nil)
+
+	// do
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bar", nil))
+
+	// check - the buffered response is passed on
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "bar", w.Header().Get("X-Foo"))
+	assert.Equal(t, "hello", w.Body.String())
+
+}
+
+func Test_Router_Timeout_RouterDefault(t *testing.T) {
+
+	// setup
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+
+	r := NewRouter()
+	r.Timeout = 10 * time.Millisecond
+	r.Handle("/default", handler, nil, nil, nil)
+	r.Handle("/none", handler, nil, nil, nil, Timeout(-1))
+
+	// do
+	defaulted := httptest.NewRecorder()
+	r.ServeHTTP(defaulted, httptest.NewRequest(http.MethodGet, "/default", nil))
+	overridden := httptest.NewRecorder()
+	r.ServeHTTP(overridden, httptest.NewRequest(http.MethodGet, "/none", nil))
+
+	// check
+	assert.Equal(t, http.StatusGatewayTimeout, defaulted.Code)
+	assert.Equal(t, http.StatusAccepted, overridden.Code)
+
+}
+
+func Test_Router_Timeout_Errors(t *testing.T) {
+
+	// setup
+	r := NewRouter()
+	r.Timeout = time.Minute
+	r.Handle("/panic", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		panic("oh no")
+	}), nil, nil, nil)
+	r.Handle("/error", ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+		w.Header().Set("X-Ignored", "no")
+		return Conflict("nope")
+	}), nil, nil, nil)
+
+	// do
+	panicked := httptest.NewRecorder()
+	r.ServeHTTP(panicked, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	errored := httptest.NewRecorder()
+	r.ServeHTTP(errored, httptest.NewRequest(http.MethodGet, "/error", nil))
+
+	// check
+	assert.Equal(t, http.StatusInternalServerError, panicked.Code)
+	assert.Equal(t, http.StatusConflict, errored.Code)
+
+}
+
+// syncBuffer is a bytes.Buffer that's safe to log to from other goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test_Router_Timeout_LateErrorsLogged(t *testing.T) {
+
+	// setup
+	logs := &syncBuffer{}
+	r := NewRouter()
+	r.Logger = slog.New(slog.NewJSONHandler(logs, nil))
+	r.Timeout = 20 * time.Millisecond
+	r.Handle("/panic", HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond) // Make sure the router's timed out first.
+		panic("late panic")
+	}), nil, nil, nil)
+	r.Handle("/error", ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) error {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond) // Make sure the router's timed out first.
+		return Conflict("late error")
+	}), nil, nil, nil)
+
+	// do
+	panicked := httptest.NewRecorder()
+	r.ServeHTTP(panicked, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	errored := httptest.NewRecorder()
+	r.ServeHTTP(errored, httptest.NewRequest(http.MethodGet, "/error", nil))
+
+	// check
+	assert.Equal(t, http.StatusGatewayTimeout, panicked.Code)
+	assert.Equal(t, http.StatusGatewayTimeout, errored.Code)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "late panic") && strings.Contains(logs.String(), "late error")
+	}, time.Second, 5*time.Millisecond, "late errors should be logged")
+
+}
+
+func Test_errTimeout(t *testing.T) {
+	assert.ErrorIs(t, errHandlingTimedOut, context.DeadlineExceeded)
+	assert.Equal(t, http.StatusGatewayTimeout, statusFor(errHandlingTimedOut))
+	assert.Equal(t, http.StatusServiceUnavailable, statusFor(errDecodeTimedOut))
+}
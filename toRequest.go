@@ -0,0 +1,104 @@
+package orbit
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// The ToRequestable interface is the opposite of FromRequestable. It lets
+// Orbit turn one of your resolved values back into the string that would
+// appear in a url param.
+//
+// Orbit uses it when building URLs with Router.URL (and so for Redirect,
+// Created and NewRequest too).
+//
+// For example, to go with the User.FromRequest example:
+//
+//	func (u User) ToRequest() string {
+//		return strconv.Itoa(u.Uid)
+//	}
+//
+// If you implement both, yourType.FromRequest(x.ToRequest()) should give you
+// back x. CheckRoundTrip can check that for you in your tests.
+type ToRequestable interface {
+	ToRequest() string
+}
+
+// paramToString turns a param's value back into the string that would appear
+// in a URL.
+//
+// Values that implement ToRequestable are converted with ToRequest, Params
+// (see ParamFunc) are converted based on the value they hold, and failing
+// that, values that implement fmt.Stringer are converted with their String
+// method. Otherwise, values whose underlying type is a string, bool, int, uint
+// or float are formatted as you'd expect. Anything else returns an error.
+func paramToString(value any) (string, error) {
+
+	if toRequestable, ok := value.(ToRequestable); ok {
+		return toRequestable.ToRequest(), nil
+	}
+
+	if wrapper, ok := value.(valueWrapper); ok {
+		return paramToString(wrapper.paramValue())
+	}
+
+	if stringer, ok := value.(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), nil
+	}
+
+	return "", fmt.Errorf("%s doesn't implement ToRequestable", reflect.TypeOf(value))
+
+}
+
+// CheckRoundTrip checks that each value survives a trip through ToRequest and
+// back through FromRequest unchanged. It returns an error describing the first
+// value that doesn't.
+//
+// It's meant for your tests. Feed it a few hand-picked values, or lots of
+// random ones (e.g. from testing/quick) to property test your param types:
+//
+//	err := orbit.CheckRoundTrip(orbit.BasicInt(0), orbit.BasicInt(-5), orbit.BasicInt(1<<40))
+//
+// Each value must implement FromRequestable as well as ToRequestable.
+// FromRequest is called on the value itself, so types that carry their own
+// settings (like BoundedInt) are checked against those settings.
+func CheckRoundTrip(values ...ToRequestable) error {
+
+	for _, value := range values {
+
+		fromRequestable, ok := value.(FromRequestable)
+		if !ok {
+			return fmt.Errorf("%s doesn't implement FromRequestable", reflect.TypeOf(value))
+		}
+
+		str := value.ToRequest()
+
+		result, err := fromRequestable.FromRequest(str)
+		if err != nil {
+			return fmt.Errorf("%#v became '%s', which FromRequest rejected (%s)", value, str, err.Error())
+		}
+
+		if !reflect.DeepEqual(result, value) {
+			return fmt.Errorf("%#v became '%s', which FromRequest turned into %#v", value, str, result)
+		}
+
+	}
+
+	return nil
+
+}
@@ -0,0 +1,67 @@
+package orbit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Dummy type whose ToRequest doesn't match its FromRequest
+type testTypeBadRoundTrip string
+
+func (x testTypeBadRoundTrip) FromRequest(param string) (any, error) {
+	return testTypeBadRoundTrip(param), nil
+}
+
+func (x testTypeBadRoundTrip) ToRequest() string {
+	return string(x) + "_oops"
+}
+
+func Test_paramToString(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr bool
+	}{
+		{name: "to_requestable", value: BasicInt(99), want: "99"},
+		{name: "stringer", value: testTypeStringer{id: "abc"}, want: "abc"},
+		{name: "string", value: testTypeString("hello"), want: "hello"},
+		{name: "int", value: testTypeInt(-12), want: "-12"},
+		{name: "uint", value: uint8(7), want: "7"},
+		{name: "float", value: 1.5, want: "1.5"},
+		{name: "bool", value: true, want: "true"},
+		{name: "struct", value: testTypeStruct{valuePassedIn: "x"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := paramToString(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("paramToString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_CheckRoundTrip_Valid(t *testing.T) {
+
+	err := CheckRoundTrip(
+		BasicString("hello"),
+		BasicString(""),
+		BasicInt(0),
+		BasicInt(-12345),
+	)
+
+	assert.NoError(t, err)
+
+}
+
+func Test_CheckRoundTrip_Mismatch(t *testing.T) {
+
+	err := CheckRoundTrip(testTypeBadRoundTrip("hello"))
+
+	assert.Error(t, err)
+
+}
@@ -7,6 +7,26 @@ import (
 	"strings"
 )
 
+// The regexes every {param} in a path has to match. They're used both to
+// match incoming requests and to check the values in generated URLs.
+//
+// paramPattern allows letters, numbers, _ and -. wideParamPattern, for routes
+// with WideParams, allows . ~ : and + too, so that things like decimals and
+// timestamps can be passed as params. The values . and .. aren't allowed
+// though (see isDotSegment).
+const (
+	paramPattern     = "[a-zA-Z0-9_-]+"
+	wideParamPattern = "[a-zA-Z0-9_.~:+-]+"
+)
+
+// isDotSegment reports whether a param's value is . or .., which
+// wideParamPattern allows, but which mean "this directory" and "the parent directory" in a
+// path. They're never treated as param values, so a route can't be tricked
+// into treating them as a file name or ID.
+func isDotSegment(value string) bool {
+	return value == "." || value == ".."
+}
+
 // Tokenise takes a precompiled route regex and an actual request path, and
 // returns a map of params + their values extracted from the request path.
 func tokenise(regex *regexp.Regexp, expectedParams []string, path string) (map[string]string, error) {
@@ -29,6 +49,9 @@ func tokenise(regex *regexp.Regexp, expectedParams []string, path string) (map[s
 	// Push the extracted params into the result map.
 	results := make(map[string]string, len(params))
 	for idx := 0; idx < len(params); idx++ {
+		if isDotSegment(params[idx]) {
+			return nil, errRouteDoesNotMatch(path)
+		}
 		results[expectedParams[idx]] = params[idx]
 	}
 
@@ -37,14 +60,15 @@ func tokenise(regex *regexp.Regexp, expectedParams []string, path string) (map[s
 }
 
 // Takes a route template (e.g. /a/b/{c}/d/{e}) and builds a regex that will
-// extract parameters from a real request path (e.g. /a/b/foo/d/bar).
+// extract parameters from a real request path (e.g. /a/b/foo/d/bar). Each
+// param has to match pattern (paramPattern or wideParamPattern).
 //
 // Returns the regex, an ordered slice of the tokens that will be matched, and
 // an err if the regexp fails to build or if the request is invalid.
 //
-// For example, buildMatcherRegex("/a/b/{param1}/d/e/{param2}/f") will return
-// (<some regex>, ["param1", "param2"], nil).
-func buildMatcherRegex(path string) (*regexp.Regexp, []string, error) {
+// For example, buildMatcherRegex("/a/b/{param1}/d/e/{param2}/f", paramPattern)
+// will return (<some regex>, ["param1", "param2"], nil).
+func buildMatcherRegex(path string, pattern string) (*regexp.Regexp, []string, error) {
 
 	// Grab the positions of braces in the path.
 	positions, err := getPositionsOfSquirlies(path)
@@ -82,7 +106,7 @@ func buildMatcherRegex(path string) (*regexp.Regexp, []string, error) {
 		names = append(names, path[tStart+1:tEnd-1])
 
 		// Add the match group to the regex.
-		rxp.WriteString("([a-zA-Z0-9_-]+)")
+		rxp.WriteString("(" + pattern + ")")
 
 	}
 
@@ -55,7 +55,7 @@ func Test_buildMatcherRegex(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, gotNames, err := buildMatcherRegex(tt.path)
+			_, gotNames, err := buildMatcherRegex(tt.path, paramPattern)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Test_buildMatcherRegex() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -70,6 +70,7 @@ func Test_IntegrationTest_tokenisation(t *testing.T) {
 		name         string
 		path         string            // Template
 		reqPath      string            // 'Real request' path
+		wide         bool              // Use wideParamPattern?
 		wantParamMap map[string]string // expected extraction
 		wantErr      bool              // want an error using regex?
 	}{
@@ -139,6 +140,64 @@ func Test_IntegrationTest_tokenisation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:    "valid_punctuation",
+			path:    "/aaa/{foo}/bbb/{bar}",
+			reqPath: "/aaa/2023-01-02T15:04:05+01:00/bbb/1.5",
+			wide:    true,
+			wantParamMap: map[string]string{
+				"foo": "2023-01-02T15:04:05+01:00",
+				"bar": "1.5",
+			},
+			wantErr: false,
+		},
+		{
+			name:         "invalid_dot_param",
+			path:         "/aaa/{foo}",
+			reqPath:      "/aaa/.",
+			wide:         true,
+			wantParamMap: nil,
+			wantErr:      true,
+		},
+		{
+			name:         "invalid_dot_dot_param",
+			path:         "/aaa/{foo}/bbb",
+			reqPath:      "/aaa/../bbb",
+			wide:         true,
+			wantParamMap: nil,
+			wantErr:      true,
+		},
+		{
+			name:    "valid_dots_in_param",
+			path:    "/aaa/{foo}",
+			reqPath: "/aaa/...",
+			wide:    true,
+			wantParamMap: map[string]string{
+				"foo": "...",
+			},
+			wantErr: false,
+		},
+		{
+			name:         "invalid_dots_in_narrow_param",
+			path:         "/aaa/{foo}",
+			reqPath:      "/aaa/report.json",
+			wantParamMap: nil,
+			wantErr:      true,
+		},
+		{
+			name:         "invalid_punctuation_in_narrow_param",
+			path:         "/aaa/{foo}",
+			reqPath:      "/aaa/2006-01-02T15:04:05+07:00",
+			wantParamMap: nil,
+			wantErr:      true,
+		},
+		{
+			name:         "invalid_slash_in_param",
+			path:         "/aaa/{foo}",
+			reqPath:      "/aaa/hello/world",
+			wantParamMap: nil,
+			wantErr:      true,
+		},
 		{
 			name:         "invalid_misconfigured_router",
 			path:         "/aaa/bbb/{foo}/{bar}/ccc/ddd/",
@@ -151,7 +210,11 @@ func Test_IntegrationTest_tokenisation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 
 			// Start by building the regex
-			regex, names, err := buildMatcherRegex(tt.path)
+			pattern := paramPattern
+			if tt.wide {
+				pattern = wideParamPattern
+			}
+			regex, names, err := buildMatcherRegex(tt.path, pattern)
 			if err != nil {
 				t.Fatalf("Tokenisation integration test: failed to build regex (%s)", err.Error())
 				return
@@ -0,0 +1,172 @@
+package orbit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Regexes that check a single value against paramPattern and
+// wideParamPattern.
+var (
+	paramValueRegex     = regexp.MustCompile("^" + paramPattern + "$")
+	wideParamValueRegex = regexp.MustCompile("^" + wideParamPattern + "$")
+)
+
+// URL builds the path for the route with the given name (see Named), filling
+// its {params} from the values in params.
+//
+// params should look like the RouteParams your handler receives: one value per
+// param in the route, of the type the route declares for it. For example, for
+// a route named "user-event" with the path /user/{user}/event/{event}:
+//
+//	path, err := r.URL("user-event", orbit.RouteParams{
+//		"user":  someUser,
+//		"event": orbit.BasicString("my-event"),
+//	})
+//	// path == "/user/123/event/my-event"
+//
+// Each value is turned back into a string with its ToRequest method (see
+// ToRequestable), or its String method if it doesn't have one. URL returns an
+// error if there's no route with that name, if any params are missing or
+// unexpected, or if any value can't be turned into a string that the route
+// would match.
+//
+// The router has to be baked (see Bake) first, since that's when it works out
+// the order of each route's params. Before then, URL returns an error rather
+// than baking the router itself, so that routes can still be added.
+func (router Router) URL(name string, params RouteParams) (string, error) {
+
+	if router.lifecycle == nil || !router.lifecycle.baked.Load() {
+		return "", errMisconfigured(fmt.Sprintf("can't build a URL for '%s' before the router's baked", name))
+	}
+	if err := router.lifecycle.bakeErr; err != nil {
+		return "", err
+	}
+
+	idx, ok := router.lifecycle.named[name]
+	if !ok {
+		return "", fmt.Errorf("no route named '%s'", name)
+	}
+
+	return router.routes[idx].url(params)
+
+}
+
+// Redirect replies to the request with a redirect to the route with the given
+// name, filled with params. code should be in the 3xx range, e.g.
+// http.StatusSeeOther.
+//
+// If the URL can't be built, nothing is written and the error is returned.
+func (router Router) Redirect(w http.ResponseWriter, r *http.Request, code int, name string, params RouteParams) error {
+
+	path, err := router.URL(name, params)
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, path, code)
+	return nil
+
+}
+
+// Created sets the Location header to point at the route with the given
+// name, filled with params, for use after creating a new resource. It
+// doesn't write the status, so you can still set other headers, and then
+// write the 201 along with the body:
+//
+//	if err := router.Created(w, "user", orbit.RouteParams{"user": user}); err != nil {
+//		return err
+//	}
+//	return orbit.JSON(w, http.StatusCreated, user)
+//
+// If the URL can't be built, the header isn't set and the error is returned.
+func (router Router) Created(w http.ResponseWriter, name string, params RouteParams) error {
+
+	path, err := router.URL(name, params)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", path)
+	return nil
+
+}
+
+// NewRequest builds a request to the route with the given name, filled with
+// params. It's handy for generating test fixtures without hardcoding paths,
+// e.g. with httptest.NewRecorder and router.ServeHTTP.
+func (router Router) NewRequest(method string, name string, params RouteParams, body io.Reader) (*http.Request, error) {
+
+	path, err := router.URL(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.NewRequest(method, path, body)
+
+}
+
+// url fills the route's path template with params. The route must be baked.
+func (r *route) url(params RouteParams) (string, error) {
+
+	// Check for params the route doesn't know about. Missing ones get caught
+	// as we fill the template in.
+	for key := range params {
+		if _, ok := r.params[key]; !ok {
+			return "", fmt.Errorf("route '%s' has no param {%s}", r.path, key)
+		}
+	}
+
+	// Positions come in pairs (start, end) for each param in the template,
+	// in the same order as orderedParamNames.
+	positions, err := getPositionsOfSquirlies(r.path)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	lastEnd := 0
+
+	for idx, key := range r.orderedParamNames {
+
+		value, ok := params[key]
+		if !ok || value == nil {
+			return "", fmt.Errorf("missing value for param {%s}", key)
+		}
+
+		// The value should be the same type the route resolves the param to.
+		if reflect.TypeOf(value) != paramType(r.params[key]) {
+			return "", fmt.Errorf("wrong type for param {%s} (want %s got %s)", key, paramType(r.params[key]), reflect.TypeOf(value))
+		}
+
+		str, err := paramToString(value)
+		if err != nil {
+			return "", fmt.Errorf("couldn't convert param {%s} to a string (%s)", key, err.Error())
+		}
+
+		// The route would never match a value outside of its pattern, so
+		// there's no point building a URL with one in.
+		valueRegex := paramValueRegex
+		if r.wideParams {
+			valueRegex = wideParamValueRegex
+		}
+		if !valueRegex.MatchString(str) || isDotSegment(str) {
+			return "", fmt.Errorf("value '%s' for param {%s} isn't allowed in a path", str, key)
+		}
+
+		result.WriteString(r.path[lastEnd:positions[idx*2]])
+		result.WriteString(url.PathEscape(str))
+		lastEnd = positions[idx*2+1]
+
+	}
+
+	result.WriteString(r.path[lastEnd:])
+
+	return result.String(), nil
+
+}
@@ -0,0 +1,250 @@
+package orbit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Dummy struct type implementing FromRequest and fmt.Stringer
+type testTypeStringer struct {
+	id string
+}
+
+func (x testTypeStringer) FromRequest(param string) (any, error) {
+	return testTypeStringer{id: param}, nil
+}
+
+func (x testTypeStringer) String() string {
+	return x.id
+}
+
+func buildURLTestRouter(t *testing.T) Router {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+
+	r := NewRouter()
+	r.Handle(
+		"/user/{user}/event/{event}",
+		handler,
+		nil,
+		RouteParams{
+			"user":  testTypeStringer{},
+			"event": testTypeInt(0),
+		},
+		nil,
+		Named("user-event"),
+	)
+	r.Handle("/about", handler, nil, nil, nil, Named("about"))
+
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	return r
+
+}
+
+func Test_Router_URL(t *testing.T) {
+
+	r := buildURLTestRouter(t)
+
+	tests := []struct {
+		name    string
+		route   string
+		params  RouteParams
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			route:  "user-event",
+			params: RouteParams{"user": testTypeStringer{id: "joe_bloggs"}, "event": testTypeInt(42)},
+			want:   "/user/joe_bloggs/event/42",
+		},
+		{
+			name:  "valid_no_params",
+			route: "about",
+			want:  "/about",
+		},
+		{
+			name:    "unknown_route",
+			route:   "nope",
+			wantErr: true,
+		},
+		{
+			name:    "missing_param",
+			route:   "user-event",
+			params:  RouteParams{"user": testTypeStringer{id: "joe"}},
+			wantErr: true,
+		},
+		{
+			name:    "unexpected_param",
+			route:   "about",
+			params:  RouteParams{"user": testTypeStringer{id: "joe"}},
+			wantErr: true,
+		},
+		{
+			name:    "wrong_type",
+			route:   "user-event",
+			params:  RouteParams{"user": testTypeString("joe"), "event": testTypeInt(42)},
+			wantErr: true,
+		},
+		{
+			name:    "value_wouldnt_match",
+			route:   "user-event",
+			params:  RouteParams{"user": testTypeStringer{id: "joe/../bloggs"}, "event": testTypeInt(42)},
+			wantErr: true,
+		},
+		{
+			name:    "value_is_dot_segment",
+			route:   "user-event",
+			params:  RouteParams{"user": testTypeStringer{id: ".."}, "event": testTypeInt(42)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.URL(tt.route, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("URL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+}
+
+func Test_Router_URL_WideParams(t *testing.T) {
+
+	// setup
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+	r := NewRouter()
+	r.Handle("/narrow/{v}", handler, nil, RouteParams{"v": testTypeString("")}, nil, Named("narrow"))
+	r.Handle("/wide/{v}", handler, nil, RouteParams{"v": testTypeString("")}, nil, Named("wide"), WideParams())
+	assert.NoError(t, r.Bake())
+
+	// do
+	_, narrowErr := r.URL("narrow", RouteParams{"v": testTypeString("1.5")})
+	wide, wideErr := r.URL("wide", RouteParams{"v": testTypeString("1.5")})
+
+	// check
+	assert.Error(t, narrowErr, "the narrow route would never match 1.5")
+	assert.NoError(t, wideErr)
+	assert.Equal(t, "/wide/1.5", wide)
+
+}
+
+func Test_Router_URL_BeforeBake(t *testing.T) {
+
+	// setup
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+	r := NewRouter()
+	r.Handle("/about", handler, nil, nil, nil, Named("about"))
+
+	// do
+	_, err := r.URL("about", nil)
+
+	// check - the router isn't baked as a side effect, so setup can carry on
+	assert.ErrorAs(t, err, new(errMisconfigured))
+	assert.NotPanics(t, func() { r.Handle("/contact", handler, nil, nil, nil, Named("contact")) })
+	assert.NoError(t, r.Bake())
+
+	path, err := r.URL("contact", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/contact", path)
+
+}
+
+func Test_Router_Bake_DuplicateNames(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {})
+
+	r := NewRouter()
+	r.Handle("/a", handler, nil, nil, nil, Named("dupe"))
+	r.Handle("/b", handler, nil, nil, nil, Named("dupe"))
+
+	assert.Error(t, r.Bake())
+
+}
+
+func Test_Router_Redirect(t *testing.T) {
+
+	r := buildURLTestRouter(t)
+
+	w := httptest.NewRecorder()
+	err := r.Redirect(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusSeeOther, "user-event", RouteParams{
+		"user":  testTypeStringer{id: "joe"},
+		"event": testTypeInt(1),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/user/joe/event/1", w.Header().Get("Location"))
+
+}
+
+func Test_Router_Created(t *testing.T) {
+
+	r := buildURLTestRouter(t)
+
+	w := httptest.NewRecorder()
+	err := r.Created(w, "user-event", RouteParams{
+		"user":  testTypeStringer{id: "joe"},
+		"event": testTypeInt(1),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/user/joe/event/1", w.Header().Get("Location"))
+
+}
+
+func Test_Router_Created_ThenJSON(t *testing.T) {
+
+	// setup
+	r := buildURLTestRouter(t)
+	w := httptest.NewRecorder()
+
+	// do
+	err := r.Created(w, "user-event", RouteParams{
+		"user":  testTypeStringer{id: "joe"},
+		"event": testTypeInt(1),
+	})
+	jsonErr := JSON(w, http.StatusCreated, map[string]string{"id": "1"})
+
+	// check
+	assert.NoError(t, err)
+	assert.NoError(t, jsonErr)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/user/joe/event/1", w.Header().Get("Location"))
+	assert.Equal(t, "application/json; charset=utf-8", w.Result().Header.Get("Content-Type"), "headers set after Created should be sent")
+
+}
+
+func Test_Router_Created_BadParams(t *testing.T) {
+
+	r := buildURLTestRouter(t)
+
+	w := httptest.NewRecorder()
+	err := r.Created(w, "user-event", nil)
+
+	assert.Error(t, err)
+	assert.Empty(t, w.Header().Get("Location"))
+
+}
+
+func Test_Router_NewRequest(t *testing.T) {
+
+	r := buildURLTestRouter(t)
+
+	req, err := r.NewRequest(http.MethodPost, "user-event", RouteParams{
+		"user":  testTypeStringer{id: "joe"},
+		"event": testTypeInt(1),
+	}, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "/user/joe/event/1", req.URL.Path)
+
+}
@@ -0,0 +1,379 @@
+package orbit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The Validatable interface lets Orbit check a value after it's been decoded.
+//
+// If a body type (or param type) has a Validate method, Orbit calls it on the
+// decoded value once FromBody (or FromRequest) has succeeded. If it returns an
+// error, the handler isn't called and Orbit responds with a 422 Unprocessable
+// Entity (unless the error is an Error with some other status).
+//
+// For simple rules, ValidateStruct can do the work for you:
+//
+//	func (e Event) Validate() error {
+//		return orbit.ValidateStruct(e)
+//	}
+type Validatable interface {
+	Validate() error
+}
+
+// validate calls value's Validate method if it has one. Errors without a
+// status of their own are given a 422.
+func validate(value any) error {
+
+	validatable, ok := value.(Validatable)
+	if !ok {
+		return nil
+	}
+
+	err := validatable.Validate()
+	if err == nil {
+		return nil
+	}
+
+	var sc statusCoder
+	if errors.As(err, &sc) && sc.StatusCode() != 0 {
+		return err
+	}
+
+	return errInvalid{err: err}
+
+}
+
+// errInvalid is an error from a Validate method, reported as a 422.
+type errInvalid struct {
+	err error
+}
+
+func (e errInvalid) Error() string {
+	return e.err.Error()
+}
+
+func (e errInvalid) Unwrap() error {
+	return e.err
+}
+
+func (e errInvalid) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// A FieldError describes one field of a struct that failed validation.
+type FieldError struct {
+	Field   string // The field's name (its json name, if it has one).
+	Rule    string // The rule it broke, e.g. "required" or "max".
+	Message string // What's wrong with it, e.g. "must be at most 10".
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// ValidationErrors is every field of a struct that failed validation, as
+// returned by ValidateStruct. It's reported as a 422.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for idx, fieldErr := range e {
+		messages[idx] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// StatusCode returns 422 Unprocessable Entity.
+func (e ValidationErrors) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// ValidateStruct checks the fields of a struct against the rules in their
+// `validate` struct tags, and returns a ValidationErrors listing every field
+// that breaks one (or nil if they're all fine).
+//
+// Rules are separated by commas:
+//
+//	type Event struct {
+//		Name      string   `json:"name" validate:"required,max=64"`
+//		Attendees []string `json:"attendees" validate:"min=1"`
+//		Kind      string   `json:"kind" validate:"oneof=party meeting"`
+//		Code      string   `json:"code" validate:"len=6,regex=^[A-Z0-9]+$"`
+//	}
+//
+// The rules are:
+//   - required: the field can't be its zero value.
+//   - min=N, max=N: for numbers, the value must be at least/at most N. For
+//     strings, slices and maps, the length must be.
+//   - len=N: strings, slices and maps must have exactly N elements.
+//   - oneof=a b c: the value must be one of the space-separated options.
+//   - regex=X: strings must match the regular expression X. Since X could
+//     contain commas, regex must be the last rule in the tag.
+//
+// Rules on pointer fields check what they point to. Nil pointers only fail
+// required, so the other rules are skipped for optional fields that are left
+// out.
+//
+// Fields that are structs (or pointers to them) are checked too, and their
+// errors are reported as parent.child. Rules that don't make sense for a
+// field's type, or that can't be parsed, return an error that will be
+// reported as a 503 since they're a bug rather than a bad request.
+func ValidateStruct(value any) error {
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return errMisconfigured(fmt.Sprintf("ValidateStruct needs a struct, not %s", rv.Type()))
+	}
+
+	var fieldErrs ValidationErrors
+	if err := validateStructValue(rv, "", &fieldErrs); err != nil {
+		return err
+	}
+
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+
+	return fieldErrs
+
+}
+
+// validateStructValue checks each field of rv, appending problems to
+// fieldErrs. prefix is prepended to field names, for nested structs.
+func validateStructValue(rv reflect.Value, prefix string, fieldErrs *ValidationErrors) error {
+
+	rt := rv.Type()
+
+	for idx := 0; idx < rt.NumField(); idx++ {
+
+		field := rt.Field(idx)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		fv := rv.Field(idx)
+
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			if err := validateField(fv, name, tag, fieldErrs); err != nil {
+				return err
+			}
+		}
+
+		// Check nested structs too.
+		nested := fv
+		if nested.Kind() == reflect.Pointer && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct {
+			if err := validateStructValue(nested, name+".", fieldErrs); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	return nil
+
+}
+
+// fieldName returns the name to use for a field in errors: its json name if it
+// has one, or its Go name otherwise.
+func fieldName(field reflect.StructField) string {
+	if jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ","); jsonName != "" && jsonName != "-" {
+		return jsonName
+	}
+	return field.Name
+}
+
+// validateField checks one field against the rules in its tag.
+func validateField(fv reflect.Value, name string, tag string, fieldErrs *ValidationErrors) error {
+
+	for tag != "" {
+
+		var rule string
+		rule, tag, _ = strings.Cut(tag, ",")
+
+		key, arg, _ := strings.Cut(rule, "=")
+
+		// regex swallows the rest of the tag, since the pattern might
+		// contain commas.
+		if key == "regex" && tag != "" {
+			arg = arg + "," + tag
+			tag = ""
+		}
+
+		message, err := checkRule(fv, key, arg)
+		if err != nil {
+			return errMisconfigured(fmt.Sprintf("bad validate rule '%s' on %s: %s", rule, name, err.Error()))
+		}
+
+		if message != "" {
+			*fieldErrs = append(*fieldErrs, FieldError{Field: name, Rule: key, Message: message})
+
+			// Don't bother with the other rules if a required field is missing.
+			if key == "required" {
+				return nil
+			}
+		}
+
+	}
+
+	return nil
+
+}
+
+// checkRule checks a value against a single rule. It returns a message saying
+// what's wrong if the value breaks the rule, or an error if the rule is bad.
+func checkRule(fv reflect.Value, key string, arg string) (string, error) {
+
+	// Rules other than required apply to what a pointer points at. Nil
+	// pointers pass them, so optional fields can be left out (use required
+	// if they can't be).
+	if key != "required" && fv.Kind() == reflect.Pointer {
+		if !knownRules[key] {
+			return "", fmt.Errorf("unknown rule")
+		}
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				return "", nil
+			}
+			fv = fv.Elem()
+		}
+	}
+
+	switch key {
+
+	case "required":
+		if fv.IsZero() {
+			return "is required", nil
+		}
+
+	case "min", "max":
+		return checkBound(fv, key, arg)
+
+	case "len":
+		want, err := strconv.Atoi(arg)
+		if err != nil {
+			return "", err
+		}
+		length, ok := lengthOf(fv)
+		if !ok {
+			return "", fmt.Errorf("len doesn't work on %s", fv.Type())
+		}
+		if length != want {
+			return fmt.Sprintf("must have a length of exactly %d", want), nil
+		}
+
+	case "oneof":
+		options := strings.Fields(arg)
+		if !contains(options, fmt.Sprint(fv.Interface())) {
+			return fmt.Sprintf("must be one of: %s", strings.Join(options, ", ")), nil
+		}
+
+	case "regex":
+		if fv.Kind() != reflect.String {
+			return "", fmt.Errorf("regex doesn't work on %s", fv.Type())
+		}
+		rx, err := cachedRegex(arg)
+		if err != nil {
+			return "", err
+		}
+		if !rx.MatchString(fv.String()) {
+			return fmt.Sprintf("must match %s", arg), nil
+		}
+
+	default:
+		return "", fmt.Errorf("unknown rule")
+
+	}
+
+	return "", nil
+
+}
+
+// knownRules is every rule checkRule understands.
+var knownRules = map[string]bool{"required": true, "min": true, "max": true, "len": true, "oneof": true, "regex": true}
+
+// checkBound checks a min or max rule. Numbers are compared by value, and
+// strings, slices and maps by length.
+func checkBound(fv reflect.Value, key string, arg string) (string, error) {
+
+	bound, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return "", err
+	}
+
+	var value float64
+	var suffix string
+
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = fv.Float()
+	default:
+		length, ok := lengthOf(fv)
+		if !ok {
+			return "", fmt.Errorf("%s doesn't work on %s", key, fv.Type())
+		}
+		value = float64(length)
+		suffix = " long"
+	}
+
+	if key == "min" && value < bound {
+		return fmt.Sprintf("must be at least %s%s", arg, suffix), nil
+	}
+	if key == "max" && value > bound {
+		return fmt.Sprintf("must be at most %s%s", arg, suffix), nil
+	}
+
+	return "", nil
+
+}
+
+// lengthOf returns the length of strings, slices, arrays and maps.
+func lengthOf(fv reflect.Value) (int, bool) {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return fv.Len(), true
+	}
+	return 0, false
+}
+
+// regexCache holds compiled regex rules, so each is only compiled once.
+var regexCache sync.Map
+
+// cachedRegex compiles pattern, or returns it from the cache if it's been
+// compiled before.
+func cachedRegex(pattern string) (*regexp.Regexp, error) {
+
+	if rx, ok := regexCache.Load(pattern); ok {
+		return rx.(*regexp.Regexp), nil
+	}
+
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	regexCache.Store(pattern, rx)
+	return rx, nil
+
+}
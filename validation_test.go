@@ -0,0 +1,267 @@
+package orbit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testValidatedAddress struct {
+	Postcode string `json:"postcode" validate:"required"`
+}
+
+type testValidatedStruct struct {
+	Name      string                `json:"name" validate:"required,max=5"`
+	Age       int                   `json:"age" validate:"min=18,max=130"`
+	Tags      []string              `json:"tags" validate:"min=1"`
+	Kind      string                `json:"kind" validate:"oneof=party meeting"`
+	Code      string                `json:"code" validate:"len=3,regex=^[A-Z]{1,3}$"`
+	Address   testValidatedAddress  `json:"address"`
+	Backup    *testValidatedAddress `json:"backup"`
+	NoJSON    string                `validate:"max=2"`
+	unchecked string
+}
+
+func (ts testValidatedStruct) FromBody(body io.ReadCloser) (any, error) {
+	var result testValidatedStruct
+	if err := json.NewDecoder(body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("couldn't decode json")
+	}
+	return result, nil
+}
+
+func (ts testValidatedStruct) Validate() error {
+	return ValidateStruct(ts)
+}
+
+// Dummy param type whose Validate returns a plain error.
+type testTypeValidatedParam string
+
+func (x testTypeValidatedParam) FromRequest(param string) (any, error) {
+	return testTypeValidatedParam(param), nil
+}
+
+func (x testTypeValidatedParam) Validate() error {
+	if x == "bad" {
+		return errors.New("can't be bad")
+	}
+	return nil
+}
+
+func validTestStruct() testValidatedStruct {
+	return testValidatedStruct{
+		Name:    "Joe",
+		Age:     30,
+		Tags:    []string{"a"},
+		Kind:    "party",
+		Code:    "ABC",
+		Address: testValidatedAddress{Postcode: "AB1"},
+	}
+}
+
+func Test_ValidateStruct_Valid(t *testing.T) {
+
+	assert.NoError(t, ValidateStruct(validTestStruct()))
+
+	valid := validTestStruct()
+	assert.NoError(t, ValidateStruct(&valid))
+
+}
+
+func Test_ValidateStruct_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(*testValidatedStruct)
+		wantField string
+		wantRule  string
+	}{
+		{name: "required", modify: func(s *testValidatedStruct) { s.Name = "" }, wantField: "name", wantRule: "required"},
+		{name: "max_length", modify: func(s *testValidatedStruct) { s.Name = "Joseph" }, wantField: "name", wantRule: "max"},
+		{name: "min_value", modify: func(s *testValidatedStruct) { s.Age = 17 }, wantField: "age", wantRule: "min"},
+		{name: "max_value", modify: func(s *testValidatedStruct) { s.Age = 131 }, wantField: "age", wantRule: "max"},
+		{name: "min_slice", modify: func(s *testValidatedStruct) { s.Tags = nil }, wantField: "tags", wantRule: "min"},
+		{name: "oneof", modify: func(s *testValidatedStruct) { s.Kind = "rave" }, wantField: "kind", wantRule: "oneof"},
+		{name: "len", modify: func(s *testValidatedStruct) { s.Code = "AB" }, wantField: "code", wantRule: "len"},
+		{name: "regex", modify: func(s *testValidatedStruct) { s.Code = "abc" }, wantField: "code", wantRule: "regex"},
+		{name: "nested", modify: func(s *testValidatedStruct) { s.Address.Postcode = "" }, wantField: "address.postcode", wantRule: "required"},
+		{name: "nested_pointer", modify: func(s *testValidatedStruct) { s.Backup = &testValidatedAddress{} }, wantField: "backup.postcode", wantRule: "required"},
+		{name: "go_name", modify: func(s *testValidatedStruct) { s.NoJSON = "abc" }, wantField: "NoJSON", wantRule: "max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			value := validTestStruct()
+			tt.modify(&value)
+
+			err := ValidateStruct(value)
+
+			var fieldErrs ValidationErrors
+			assert.True(t, errors.As(err, &fieldErrs), "expected ValidationErrors, got %v", err)
+			assert.Len(t, fieldErrs, 1)
+			assert.Equal(t, tt.wantField, fieldErrs[0].Field)
+			assert.Equal(t, tt.wantRule, fieldErrs[0].Rule)
+			assert.Equal(t, 422, statusFor(err))
+
+		})
+	}
+}
+
+type testValidatedPointers struct {
+	Nickname *string `json:"nickname" validate:"max=5"`
+	Mood     *string `json:"mood" validate:"oneof=happy sad"`
+	Limit    *int    `json:"limit" validate:"required,min=1"`
+}
+
+func Test_ValidateStruct_Pointers(t *testing.T) {
+
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+
+	tests := []struct {
+		name      string
+		value     testValidatedPointers
+		wantField string
+		wantRule  string
+	}{
+		{name: "valid", value: testValidatedPointers{Nickname: str("Jo"), Mood: str("happy"), Limit: num(1)}},
+		{name: "nil_optional", value: testValidatedPointers{Limit: num(1)}},
+		{name: "nil_required", value: testValidatedPointers{}, wantField: "limit", wantRule: "required"},
+		{name: "max", value: testValidatedPointers{Nickname: str("Joseph"), Limit: num(1)}, wantField: "nickname", wantRule: "max"},
+		{name: "oneof", value: testValidatedPointers{Mood: str("meh"), Limit: num(1)}, wantField: "mood", wantRule: "oneof"},
+		{name: "min", value: testValidatedPointers{Limit: num(0)}, wantField: "limit", wantRule: "min"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			err := ValidateStruct(tt.value)
+
+			if tt.wantField == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fieldErrs ValidationErrors
+			assert.True(t, errors.As(err, &fieldErrs), "expected ValidationErrors, got %v", err)
+			assert.Len(t, fieldErrs, 1)
+			assert.Equal(t, tt.wantField, fieldErrs[0].Field)
+			assert.Equal(t, tt.wantRule, fieldErrs[0].Rule)
+
+		})
+	}
+
+}
+
+func Test_ValidateStruct_SeveralErrors(t *testing.T) {
+
+	value := validTestStruct()
+	value.Name = ""
+	value.Age = 3
+
+	err := ValidateStruct(value)
+
+	assert.Equal(t, "name is required; age must be at least 18", err.Error())
+
+}
+
+func Test_ValidateStruct_BadRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{name: "not_a_struct", value: "hello"},
+		{name: "unknown_rule", value: struct {
+			A string `validate:"shiny"`
+		}{}},
+		{name: "bad_number", value: struct {
+			A string `validate:"max=lots"`
+		}{}},
+		{name: "bad_type", value: struct {
+			A bool `validate:"max=2"`
+		}{}},
+		{name: "bad_regex", value: struct {
+			A string `validate:"regex=("`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStruct(tt.value)
+			assert.Error(t, err)
+			assert.Equal(t, 503, statusFor(err))
+		})
+	}
+}
+
+func Test_validate_PlainError(t *testing.T) {
+
+	err := validate(testTypeValidatedParam("bad"))
+
+	assert.EqualError(t, err, "can't be bad")
+	assert.Equal(t, 422, statusFor(err))
+
+}
+
+func Test_validate_NotValidatable(t *testing.T) {
+
+	assert.NoError(t, validate(testTypeString("anything")))
+
+}
+
+func Test_tryFromBody_Validates(t *testing.T) {
+
+	inputBuf := io.NopCloser(bytes.NewBufferString(`{"name": "Joseph"}`))
+
+	_, err := tryFromBody(testValidatedStruct{}, inputBuf)
+
+	assert.Error(t, err)
+	assert.Equal(t, 422, statusFor(err))
+
+}
+
+func Test_Router_E2E_InvalidBody(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Fatalf("handler was called when it shouldn't have been")
+	})
+
+	r := NewRouter()
+	r.Handle("/a", handler, nil, nil, testValidatedStruct{})
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/a", strings.NewReader(`{
+		"age": 30,
+		"tags": ["a"],
+		"kind": "party",
+		"code": "ABC",
+		"address": {"postcode": "AB1"}
+	}`)))
+
+	assert.Equal(t, 422, w.Code)
+	assert.Equal(t, "name is required\n", w.Body.String())
+
+}
+
+func Test_Router_E2E_InvalidParam(t *testing.T) {
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request, params RouteParams, body FromBodyable) {
+		t.Fatalf("handler was called when it shouldn't have been")
+	})
+
+	r := NewRouter()
+	r.Handle("/a/{b}", handler, nil, RouteParams{"b": testTypeValidatedParam("")}, nil)
+	assert.NoError(t, r.Bake(), "router bake failed")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a/bad", nil))
+
+	assert.Equal(t, 422, w.Code)
+
+}